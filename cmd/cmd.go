@@ -34,4 +34,6 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(resetpassCmd)
+	rootCmd.AddCommand(resettotpCmd)
+	rootCmd.AddCommand(importCmd)
 }
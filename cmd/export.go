@@ -6,18 +6,25 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
 	"github.com/daeuniverse/dae-wing/graphql"
+	"github.com/daeuniverse/dae-wing/graphql/service/config"
 	daeConfig "github.com/daeuniverse/dae/config"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
+	exportRedactLinks bool
+
 	exportCmd = &cobra.Command{
 		Use:   "export",
 		Short: "Export development related information",
@@ -52,10 +59,41 @@ var (
 			fmt.Println(string(b))
 		},
 	}
+	exportConfigCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Export the dae config that a run would load from the selected config, dns and routing",
+		Run: func(cmd *cobra.Command, args []string) {
+			if cfgDir == "" {
+				logrus.Fatalln("Argument \"--config\" or \"-c\" is required but not provided.")
+			}
+			if _, err := os.Stat(cfgDir); err != nil {
+				logrus.Fatalln(err)
+			}
+			// Read config from --config cfgDir.
+			if err := db.InitDatabase(cfgDir); err != nil {
+				logrus.Fatalln("Failed to init db:", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			a, err := config.Assemble(db.DB(ctx))
+			if err != nil {
+				logrus.Fatalln(err)
+			}
+			rendered, err := config.Render(a, exportRedactLinks)
+			if err != nil {
+				logrus.Fatalln(err)
+			}
+			fmt.Print(rendered)
+		},
+	}
 )
 
 func init() {
 	exportCmd.AddCommand(exportSchemaCmd)
 	exportCmd.AddCommand(exportOutlineCmd)
 	exportCmd.AddCommand(exportFlatDescCmd)
+	exportCmd.AddCommand(exportConfigCmd)
+
+	exportConfigCmd.PersistentFlags().StringVarP(&cfgDir, "config", "c", filepath.Join("/etc", db.AppName), "config directory")
+	exportConfigCmd.PersistentFlags().BoolVar(&exportRedactLinks, "redact-links", false, "redact links of nodes")
 }
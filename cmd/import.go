@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/cmd/internal"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/importer"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importName          string
+	importRollbackError bool
+
+	importCmd = &cobra.Command{
+		Use:   "import <config.dae | ->",
+		Short: "Import a complete dae config file into the database",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if cfgDir == "" {
+				logrus.Fatalln("Argument \"--config\" or \"-c\" is required but not provided.")
+			}
+			if _, err := os.Stat(cfgDir); err != nil {
+				logrus.Fatalln(err)
+			}
+
+			// Require "sudo" if necessary.
+			if !apiOnly {
+				internal.AutoSu()
+			}
+
+			var raw []byte
+			var err error
+			if args[0] == "-" {
+				raw, err = io.ReadAll(os.Stdin)
+			} else {
+				raw, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				logrus.Fatalln(err)
+			}
+
+			// Read config from --config cfgDir.
+			if err := db.InitDatabase(cfgDir); err != nil {
+				logrus.Fatalln("Failed to init db:", err)
+			}
+
+			// Subscriptions are fetched during the import.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+			result, err := importer.Import(ctx, importName, string(raw), importRollbackError)
+			if err != nil {
+				logrus.Fatalln("Failed to import:", err)
+			}
+			fmt.Printf("Config, dns and routing: %v\n", result.Config.Name())
+			var nFailed int
+			for i, n := range result.Nodes {
+				if n.Error != nil {
+					nFailed++
+					fmt.Printf("Node #%v: %v\n", i+1, *n.Error)
+				}
+			}
+			fmt.Printf("Nodes: %v imported, %v failed\n", len(result.Nodes)-nFailed, nFailed)
+			fmt.Printf("Subscriptions: %v imported\n", len(result.Subscriptions))
+			for _, g := range result.Groups {
+				fmt.Printf("Group: %v\n", g.Name())
+			}
+			for _, u := range result.Unmapped {
+				fmt.Printf("Not imported: %v\n", u)
+			}
+		},
+	}
+)
+
+func init() {
+	importCmd.PersistentFlags().StringVarP(&cfgDir, "config", "c", filepath.Join("/etc", db.AppName), "config directory")
+	importCmd.PersistentFlags().StringVarP(&importName, "name", "n", importer.DefaultName, "name of imported config, dns and routing")
+	importCmd.PersistentFlags().BoolVar(&importRollbackError, "rollback-error", false, "abort the whole import if any node or subscription fails to import")
+}
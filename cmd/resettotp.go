@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/cmd/internal"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resettotpCmd = &cobra.Command{
+		Use:   "resettotp [username ...]",
+		Short: "Disable two-factor authentication for given accounts, or every account if none is given",
+		Run: func(cmd *cobra.Command, args []string) {
+			if cfgDir == "" {
+				logrus.Fatalln("Argument \"--config\" or \"-c\" is required but not provided.")
+			}
+			if _, err := os.Stat(cfgDir); err != nil {
+				logrus.Fatalln(err)
+			}
+
+			// Require "sudo" if necessary.
+			if !apiOnly {
+				internal.AutoSu()
+			}
+
+			// Read config from --config cfgDir.
+			if err := db.InitDatabase(cfgDir); err != nil {
+				logrus.Fatalln("Failed to init db:", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			q := db.DB(ctx).Where("totp_enabled = ? OR totp_secret != ?", true, "")
+			if len(args) > 0 {
+				q = q.Where("username IN ?", args)
+			}
+			var users []db.User
+			if err := q.Find(&users).Error; err != nil {
+				logrus.Fatalln(err)
+			}
+			for _, u := range users {
+				if err := graphql.DisableTotp(ctx, &u); err != nil {
+					logrus.Fatalf("Username: %v: %v", u.Username, err)
+				}
+				fmt.Printf("Username: %v, two-factor authentication disabled\n", u.Username)
+			}
+		},
+	}
+)
+
+func init() {
+	resettotpCmd.PersistentFlags().StringVarP(&cfgDir, "config", "c", filepath.Join("/etc", db.AppName), "config directory")
+}
@@ -18,11 +18,18 @@ import (
 	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
 	"github.com/daeuniverse/dae-wing/graphql"
+	"github.com/daeuniverse/dae-wing/graphql/service/apitoken"
 	"github.com/daeuniverse/dae-wing/graphql/service/config"
+	"github.com/daeuniverse/dae-wing/graphql/service/logs"
+	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae-wing/graphql/service/session"
 
 	"github.com/daeuniverse/dae-wing/graphql/service/subscription"
+	"github.com/daeuniverse/dae-wing/metrics"
+	"github.com/daeuniverse/dae-wing/pkg/graphqlws"
 	"github.com/daeuniverse/dae-wing/webrender"
 	"github.com/golang-jwt/jwt/v5"
+	graphqlGo "github.com/graph-gophers/graphql-go"
 	"github.com/graph-gophers/graphql-go/relay"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
@@ -38,6 +45,8 @@ func init() {
 	runCmd.PersistentFlags().IntVar(&logFileMaxSize, "logfile-maxsize", 30, "Unit: MB. The maximum size in megabytes of the log file before it gets rotated.")
 	runCmd.PersistentFlags().IntVar(&logFileMaxBackups, "logfile-maxbackups", 3, "The maximum number of old log files to retain.")
 	runCmd.PersistentFlags().BoolVarP(&disableTimestamp, "disable-timestamp", "", false, "disable timestamp")
+	runCmd.PersistentFlags().BoolVar(&enableMetrics, "metrics", false, "serve Prometheus metrics on /metrics to api tokens or users with the viewer role")
+	runCmd.PersistentFlags().DurationVar(&healthCheckInterval, "health-check-interval", 30*time.Minute, "interval to check health of all nodes and record it. 0 means disabled.")
 }
 
 func _errorExit(err error) {
@@ -53,13 +62,15 @@ func errorExit(err error) {
 }
 
 var (
-	cfgDir            string
-	logFile           string
-	logFileMaxSize    int
-	logFileMaxBackups int
-	disableTimestamp  bool
-	listen            string
-	apiOnly           bool
+	cfgDir              string
+	logFile             string
+	logFileMaxSize      int
+	logFileMaxBackups   int
+	disableTimestamp    bool
+	listen              string
+	apiOnly             bool
+	enableMetrics       bool
+	healthCheckInterval time.Duration
 
 	runCmd = &cobra.Command{
 		Use:   "run",
@@ -83,6 +94,11 @@ var (
 			}
 
 			subscription.UpdateAll(context.TODO())
+			if healthCheckInterval > 0 {
+				if err := node.StartHealthCheck(healthCheckInterval); err != nil {
+					logrus.Errorln("Failed to start health check:", err)
+				}
+			}
 
 			// Run dae.
 			var logOpts *lumberjack.Logger
@@ -98,6 +114,8 @@ var (
 				logrus.SetOutput(logOpts)
 				db.SetOutput(logOpts)
 			}
+			// Keep logs in memory for the logs query and streams.
+			logrus.AddHook(logs.Hook)
 			go func() {
 				if err := dae.Run(
 					logrus.StandardLogger(),
@@ -120,8 +138,16 @@ var (
 			if err != nil {
 				errorExit(err)
 			}
+			readOnlySchema, err := graphql.ReadOnlySchema()
+			if err != nil {
+				errorExit(err)
+			}
 			mux := http.NewServeMux()
-			mux.Handle("/graphql", auth(cors.AllowAll().Handler(&relay.Handler{Schema: schema})))
+			mux.Handle("/graphql", auth(cors.AllowAll().Handler(graphqlHandler(schema, readOnlySchema))))
+			mux.Handle("/logs/stream", auth(cors.AllowAll().Handler(logs.SseHandler())))
+			if enableMetrics {
+				mux.Handle("/metrics", auth(metrics.Handler()))
+			}
 			if err = webrender.Handle(mux); err != nil {
 				errorExit(err)
 			}
@@ -154,6 +180,14 @@ var (
 )
 
 func restoreRunningState() (err error) {
+	// A run left unconfirmed is rolled back, or waits for confirmation for the rest of its time.
+	restored, err := config.RestoreUnconfirmed(context.TODO())
+	if err != nil {
+		return err
+	}
+	if restored {
+		return nil
+	}
 	reload, err := shouldReload()
 	if err != nil {
 		return err
@@ -161,11 +195,8 @@ func restoreRunningState() (err error) {
 	if !reload {
 		return nil
 	}
-	tx := db.BeginTx(context.TODO())
 	// Reload.
-	if _, err = config.Run(tx, false); err != nil {
-		tx.Rollback()
-
+	if _, err = config.Run(context.TODO(), false, 0); err != nil {
 		// Another tx.
 		// Set running = false.
 		tx2 := db.BeginTx(context.TODO())
@@ -183,7 +214,6 @@ func restoreRunningState() (err error) {
 		tx2.Commit()
 		return err
 	}
-	tx.Commit()
 	return nil
 }
 
@@ -211,38 +241,123 @@ func shouldReload() (ok bool, err error) {
 	return true, nil
 }
 
-func auth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authorization := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-		var user db.User
-		token, err := jwt.Parse(authorization, func(token *jwt.Token) (interface{}, error) {
-			// Don't forget to validate the alg is what you expect:
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			// Get corresponding secret.
-			subject, err := token.Claims.GetSubject()
-			if err != nil {
-				return nil, err
+// graphqlHandler serves read-only api tokens with a schema without mutations. WebSocket requests are served with
+// subscriptions.
+func graphqlHandler(schema *graphqlGo.Schema, readOnlySchema *graphqlGo.Schema) http.Handler {
+	full := &relay.Handler{Schema: schema}
+	readOnly := &relay.Handler{Schema: readOnlySchema}
+	isReadOnly := func(ctx context.Context) bool {
+		t, ok := ctx.Value("apiToken").(*db.ApiToken)
+		return ok && t.Scope == db.ApiTokenScopeReadOnly
+	}
+	ws := &graphqlws.Server{
+		Schema: func(ctx context.Context) *graphqlGo.Schema {
+			if isReadOnly(ctx) {
+				return readOnlySchema
 			}
-			q := db.DB(context.TODO()).Model(&db.User{}).Where("username = ?", subject).First(&user)
-			if q.Error != nil {
-				return nil, q.Error
+			return schema
+		},
+		// Browsers cannot set headers of WebSocket requests, so the token can also be given in the init payload.
+		Init: func(ctx context.Context, payload map[string]interface{}) (context.Context, error) {
+			if _, ok := ctx.Value("role").(string); !ok {
+				authorization, _ := payload["Authorization"].(string)
+				if authorization == "" {
+					authorization, _ = payload["authorization"].(string)
+				}
+				ctx = authenticate(ctx, authorization)
 			}
-			if q.RowsAffected == 0 {
-				return nil, fmt.Errorf("no such user")
+			if _, ok := ctx.Value("role").(string); !ok {
+				return nil, fmt.Errorf("unauthorized")
 			}
-			return []byte(user.JwtSecret), nil
-		})
+			return ctx, nil
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if graphqlws.IsUpgrade(r) {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		if isReadOnly(r.Context()) {
+			readOnly.ServeHTTP(w, r)
+			return
+		}
+		full.ServeHTTP(w, r)
+	})
+}
+
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.Background()
-		if err == nil {
-			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				if expireAt, err := token.Claims.GetExpirationTime(); err == nil && time.Now().Before(expireAt.Time) {
-					ctx = context.WithValue(ctx, "role", claims["role"])
-					ctx = context.WithValue(ctx, "user", &user)
-				}
+		ctx = context.WithValue(ctx, "clientIp", clientIp(r))
+		ctx = context.WithValue(ctx, "userAgent", r.UserAgent())
+		ctx = authenticate(ctx, r.Header.Get("Authorization"))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate returns ctx with the role and the user of the JWT or api token in authorization, or ctx itself if it is
+// not valid.
+func authenticate(ctx context.Context, authorization string) context.Context {
+	ip, _ := ctx.Value("clientIp").(string)
+	authorization = strings.TrimPrefix(authorization, "Bearer ")
+	if apitoken.IsApiToken(authorization) {
+		if t, err := apitoken.Authenticate(ctx, authorization); err == nil {
+			role := t.User.Role
+			if t.Scope == db.ApiTokenScopeReadOnly {
+				role = db.RoleViewer
 			}
+			ctx = context.WithValue(ctx, "role", role)
+			ctx = context.WithValue(ctx, "user", &t.User)
+			ctx = context.WithValue(ctx, "apiToken", t)
 		}
-		next.ServeHTTP(w, r.WithContext(ctx))
+		return ctx
+	}
+	var user db.User
+	token, err := jwt.Parse(authorization, func(token *jwt.Token) (interface{}, error) {
+		// Don't forget to validate the alg is what you expect:
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		// Get corresponding secret.
+		subject, err := token.Claims.GetSubject()
+		if err != nil {
+			return nil, err
+		}
+		q := db.DB(context.TODO()).Model(&db.User{}).Where("username = ?", subject).First(&user)
+		if q.Error != nil {
+			return nil, q.Error
+		}
+		if q.RowsAffected == 0 {
+			return nil, fmt.Errorf("no such user")
+		}
+		return []byte(user.JwtSecret), nil
 	})
+	if err == nil {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			if expireAt, err := token.Claims.GetExpirationTime(); err == nil && time.Now().Before(expireAt.Time) {
+				// Tokens issued before sessions were introduced carry no jti and cannot be revoked; reject them so
+				// that their holders log in again.
+				jti, _ := claims["jti"].(string)
+				if jti == "" {
+					return ctx
+				}
+				if err = session.Touch(ctx, user.ID, jti, ip); err != nil {
+					return ctx
+				}
+				ctx = context.WithValue(ctx, "jti", jti)
+				// Take the role from database rather than claims so that role changes take effect immediately.
+				ctx = context.WithValue(ctx, "role", user.Role)
+				ctx = context.WithValue(ctx, "user", &user)
+			}
+		}
+	}
+	return ctx
 }
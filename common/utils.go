@@ -94,3 +94,12 @@ func GetIfAddrs() (globalIfAddrs []string, err error) {
 	}
 	return daeCommon.Deduplicate(globalIfAddrs), nil
 }
+
+// RedactLink keeps only the scheme of a node or subscription link, which may carry credentials.
+func RedactLink(link string) string {
+	scheme, _, found := strings.Cut(link, "://")
+	if !found {
+		return "[REDACTED]"
+	}
+	return scheme + "://[REDACTED]"
+}
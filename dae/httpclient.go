@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/netip"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/daeuniverse/dae/common/consts"
@@ -14,32 +15,48 @@ import (
 	"github.com/mzz2017/softwind/netproxy"
 )
 
+// RouteDialTcp dials a TCP connection to addr through the control plane as if it was dialed by dae itself.
+func RouteDialTcp(ctx context.Context, addr string) (net.Conn, error) {
+	host, _port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no dns record: %v", host)
+	}
+	port, err := strconv.ParseUint(_port, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	ctl, err := ControlPlane()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ctl.RouteDialTcp(&control.RouteDialParam{Outbound: consts.OutboundControlPlaneRouting, Domain: host, Mac: [6]uint8{}, ProcessName: [16]uint8{}, Src: netip.MustParseAddrPort("0.0.0.0:0"), Dest: netip.AddrPortFrom(addrs[0], uint16(port)), Mark: 0})
+	if err != nil {
+		return nil, err
+	}
+	return &netproxy.FakeNetConn{Conn: conn, LAddr: nil, RAddr: nil}, nil
+}
+
+var tcpNetwork atomic.Value
+
+// TcpNetwork returns the network for dialers built from node links to dial TCP with. It carries the mark of dae so that
+// the traffic is not routed by dae again.
+func TcpNetwork() string {
+	if network, ok := tcpNetwork.Load().(string); ok {
+		return network
+	}
+	return "tcp"
+}
+
 var HttpTransport = &http.Transport{
 	DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-		host, _port, err := net.SplitHostPort(addr)
-		if err != nil {
-			return nil, err
-		}
-		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
-		if err != nil {
-			return nil, err
-		}
-		if len(addrs) == 0 {
-			return nil, fmt.Errorf("no dns record: %v", host)
-		}
-		port, err := strconv.ParseUint(_port, 10, 16)
-		if err != nil {
-			return nil, err
-		}
-		ctl, err := ControlPlane()
-		if err != nil {
-			return nil, err
-		}
-		conn, err := ctl.RouteDialTcp(&control.RouteDialParam{Outbound: consts.OutboundControlPlaneRouting, Domain: host, Mac: [6]uint8{}, ProcessName: [16]uint8{}, Src: netip.MustParseAddrPort("0.0.0.0:0"), Dest: netip.AddrPortFrom(addrs[0], uint16(port)), Mark: 0})
-		if err != nil {
-			return nil, err
-		}
-		return &netproxy.FakeNetConn{Conn: conn, LAddr: nil, RAddr: nil}, nil
+		return RouteDialTcp(ctx, addr)
 	},
 	TLSHandshakeTimeout:   10 * time.Second,
 	DisableKeepAlives:     true,
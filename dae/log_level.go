@@ -0,0 +1,81 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package dae
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	logLevelMu sync.Mutex
+	// daeLog is the logger of the control plane, which is replaced on every reload.
+	daeLog *logrus.Logger
+	// configuredLogLevel is the log level of the running config.
+	configuredLogLevel = logrus.InfoLevel
+	// overriddenLogLevel is set by SetLogLevel and takes precedence over configuredLogLevel.
+	overriddenLogLevel *logrus.Level
+	logLevelTimer      *time.Timer
+)
+
+// SetLogLevel changes the level of loggers without reloading. The level is kept across reloads and reverts to the
+// configured one after duration if it is positive.
+func SetLogLevel(level logrus.Level, duration time.Duration) {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	if logLevelTimer != nil {
+		logLevelTimer.Stop()
+		logLevelTimer = nil
+	}
+	overriddenLogLevel = &level
+	setLevel(level)
+	logrus.Infoln("Log level set to", level)
+	if duration > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(duration, func() {
+			logLevelMu.Lock()
+			defer logLevelMu.Unlock()
+			if logLevelTimer != timer {
+				// Overridden again.
+				return
+			}
+			logLevelTimer = nil
+			overriddenLogLevel = nil
+			setLevel(configuredLogLevel)
+			logrus.Infoln("Log level reverted to", configuredLogLevel)
+		})
+		logLevelTimer = timer
+	}
+}
+
+// LogLevel returns the current level of loggers.
+func LogLevel() logrus.Level {
+	return logrus.GetLevel()
+}
+
+// applyLogLevel records the logger and the log level of the new config, applying the overridden level if any.
+func applyLogLevel(log *logrus.Logger, strLevel string) {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	daeLog = log
+	if level, err := logrus.ParseLevel(strLevel); err == nil {
+		configuredLogLevel = level
+	} else {
+		configuredLogLevel = logrus.InfoLevel
+	}
+	if overriddenLogLevel != nil {
+		setLevel(*overriddenLogLevel)
+	}
+}
+
+func setLevel(level logrus.Level) {
+	logrus.SetLevel(level)
+	if daeLog != nil {
+		daeLog.SetLevel(level)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package dae
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/daeuniverse/dae/component/outbound"
+	"github.com/daeuniverse/dae/control"
+)
+
+// DialerGroup returns the dialer group with the name in the running control plane, or nil if it is not loaded.
+func DialerGroup(name string) (*outbound.DialerGroup, error) {
+	ctl, err := ControlPlane()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := outbounds(ctl)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Name == name {
+			return g, nil
+		}
+	}
+	return nil, nil
+}
+
+// outbounds reads the dialer groups of the control plane, which dae does not export. They are set up in
+// NewControlPlane and never changed, so it is safe to read them without a lock. The field is checked before it is
+// read so that an incompatible version of dae yields an error instead of memory corruption.
+func outbounds(ctl *control.ControlPlane) ([]*outbound.DialerGroup, error) {
+	f := reflect.ValueOf(ctl).Elem().FieldByName("outbounds")
+	if !f.IsValid() {
+		return nil, fmt.Errorf("unsupported dae: ControlPlane has no field outbounds")
+	}
+	if want := reflect.TypeOf([]*outbound.DialerGroup(nil)); f.Type() != want {
+		return nil, fmt.Errorf("unsupported dae: ControlPlane.outbounds is %v rather than %v", f.Type(), want)
+	}
+	return *(*[]*outbound.DialerGroup)(unsafe.Pointer(f.UnsafeAddr())), nil
+}
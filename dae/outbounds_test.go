@@ -0,0 +1,19 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package dae
+
+import (
+	"testing"
+
+	"github.com/daeuniverse/dae/control"
+)
+
+// TestOutbounds fails if the pinned dae changes the unexported field that outbounds reads.
+func TestOutbounds(t *testing.T) {
+	if _, err := outbounds(&control.ControlPlane{}); err != nil {
+		t.Fatal(err)
+	}
+}
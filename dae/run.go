@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"sync"
 
+	"github.com/daeuniverse/dae/common"
 	"github.com/daeuniverse/dae/common/netutils"
 	daeConfig "github.com/daeuniverse/dae/config"
 	"github.com/daeuniverse/dae/control"
@@ -57,6 +58,8 @@ func Run(log *logrus.Logger, conf *daeConfig.Config, externGeoDataDirs []string,
 	// Not really run dae.
 	if dry {
 		log.Infoln("Dry run in api-only mode")
+		// Dialers built from node links dial through them.
+		direct.InitDirectDialers(conf.Global.FallbackResolver)
 	dryLoop:
 		for newConf := range ChReloadConfigs {
 			switch newConf {
@@ -141,8 +144,15 @@ loop:
 			// New logger.
 			oldLogOutput := log.Out
 			log = logrus.New()
+			/* dae-wing start */
+			// Keep hooks such as the in-memory logs of the API.
+			log.ReplaceHooks(logrus.StandardLogger().Hooks)
+			/* dae-wing end */
 			logger.SetLogger(log, newConf.Global.LogLevel, disableTimestamp, nil)
 			logger.SetLogger(logrus.StandardLogger(), newConf.Global.LogLevel, disableTimestamp, nil)
+			/* dae-wing start */
+			applyLogLevel(log, newConf.Global.LogLevel)
+			/* dae-wing end */
 			log.SetOutput(oldLogOutput) // FIXME: THIS IS A HACK.
 			logrus.SetOutput(oldLogOutput)
 
@@ -218,6 +228,9 @@ func newControlPlane(log *logrus.Logger, bpf interface{}, dnsCache map[string]*c
 	// Init Direct Dialers.
 	direct.InitDirectDialers(conf.Global.FallbackResolver)
 	netutils.FallbackDns = netip.MustParseAddrPort(conf.Global.FallbackResolver)
+	/* dae-wing start */
+	tcpNetwork.Store(common.MagicNetwork("tcp", conf.Global.SoMarkFromDae, conf.Global.Mptcp))
+	/* dae-wing end */
 
 	if !conf.Global.DisableWaitingNetwork && len(conf.Global.WanInterface) > 0 {
 		// Wait for network for WAN ready.
@@ -0,0 +1,93 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package dae
+
+import (
+	"fmt"
+)
+
+// RawSection is a top-level section of a dae config with its source text, comments included.
+type RawSection struct {
+	Name string
+	// Text is like "name {...}".
+	Text string
+}
+
+// SplitSections splits a dae config into top-level sections in order.
+func SplitSections(raw string) (sections []RawSection, err error) {
+	i := 0
+	skip := func() {
+		for i < len(raw) {
+			switch raw[i] {
+			case ' ', '\t', '\r', '\n':
+				i++
+			case '#':
+				for i < len(raw) && raw[i] != '\n' {
+					i++
+				}
+			default:
+				return
+			}
+		}
+	}
+	for skip(); i < len(raw); skip() {
+		start := i
+		for i < len(raw) && isIdentChar(raw[i]) {
+			i++
+		}
+		name := raw[start:i]
+		if name == "" {
+			return nil, fmt.Errorf("unexpected %q at offset %v", raw[i], i)
+		}
+		skip()
+		if i >= len(raw) || raw[i] != '{' {
+			return nil, fmt.Errorf("section %v: '{' expected", name)
+		}
+		depth := 0
+		var quote byte
+	scan:
+		for ; i < len(raw); i++ {
+			c := raw[i]
+			switch {
+			case quote != 0:
+				if c == '\\' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+			case c == '#':
+				for i < len(raw) && raw[i] != '\n' {
+					i++
+				}
+			case c == '{':
+				depth++
+			case c == '}':
+				depth--
+				if depth == 0 {
+					i++
+					break scan
+				}
+			}
+		}
+		if depth != 0 {
+			return nil, fmt.Errorf("section %v: unbalanced braces", name)
+		}
+		sections = append(sections, RawSection{
+			Name: name,
+			Text: raw[start:i],
+		})
+	}
+	return sections, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' ||
+		('a' <= c && c <= 'z') ||
+		('A' <= c && c <= 'Z') ||
+		('0' <= c && c <= '9')
+}
@@ -0,0 +1,28 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import "time"
+
+const (
+	ApiTokenScopeReadOnly = "read_only"
+	ApiTokenScopeFull     = "full"
+)
+
+type ApiToken struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	Name      string `gorm:"not null"`
+	TokenHash string `gorm:"not null;unique;index"` // SHA-256 of the token in hex.
+	Scope     string `gorm:"not null"`
+
+	CreatedAt  time.Time `gorm:"not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+
+	// Foreign keys.
+	UserID uint `gorm:"not null;index"`
+	User   User
+}
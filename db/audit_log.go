@@ -0,0 +1,23 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import "time"
+
+// AuditLog records a mutation. Records are never updated and only removed when they exceed the retention.
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time `gorm:"not null;index"`
+	UserID    *uint     `gorm:"index"`
+	// Username is kept in case the user is removed.
+	Username string `gorm:"not null;default:''"`
+	ClientIp string `gorm:"not null;default:''"`
+	Mutation string `gorm:"not null;index"`
+	// Args is sanitized arguments in json.
+	Args   string `gorm:"not null;default:''"`
+	Result string `gorm:"not null;default:''"`
+	Error  string `gorm:"not null;default:''"`
+}
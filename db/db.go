@@ -42,13 +42,28 @@ func InitDatabase(configDir string) (err error) {
 		&Dns{},
 		&Routing{},
 		&Node{},
+		&NodeHealthSample{},
+		&NodeHealthStat{},
 		&Subscription{},
 		&Group{},
 		&GroupPolicyParam{},
+		&GroupFilter{},
 		&System{},
+		&ApiToken{},
+		&Session{},
+		&RecoveryCode{},
+		&LoginAttempt{},
+		&OidcConfig{},
+		&OidcIdentity{},
+		&AuditLog{},
+		&Revision{},
+		&RunProbe{},
 	); err != nil {
 		return err
 	}
+	if err = migrateFixedPolicyParams(db); err != nil {
+		return err
+	}
 	if fi, err := os.Stat(path); err != nil {
 		return err
 	} else if fi.Mode()&0037 > 0 {
@@ -61,6 +76,26 @@ func InitDatabase(configDir string) (err error) {
 	return nil
 }
 
+// migrateFixedPolicyParams gives groups with policy fixed and no param the index 0, which dae used to take
+// implicitly. The index is required since policies are validated.
+func migrateFixedPolicyParams(d *gorm.DB) error {
+	var ids []uint
+	if err := d.Model(&Group{}).
+		Where("policy = ?", "fixed").
+		Where("id not in (?)", d.Model(&GroupPolicyParam{}).Select("group_id")).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	params := make([]GroupPolicyParam, 0, len(ids))
+	for _, id := range ids {
+		params = append(params, GroupPolicyParam{Key: "", Value: "0", GroupID: id})
+	}
+	return d.Omit("Group").Create(&params).Error
+}
+
 func DB(ctx context.Context) *gorm.DB {
 	return db.WithContext(ctx)
 }
@@ -0,0 +1,48 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrateFixedPolicyParams(t *testing.T) {
+	dir := t.TempDir()
+	if err := InitDatabase(dir); err != nil {
+		t.Fatal(err)
+	}
+	d := DB(context.Background())
+	groups := []Group{
+		{Name: "legacy", Policy: "fixed"},
+		{Name: "indexed", Policy: "fixed", PolicyParams: []GroupPolicyParam{{Value: "1"}}},
+		{Name: "random", Policy: "random"},
+	}
+	if err := d.Create(&groups).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Migrate twice to check that it is idempotent.
+	for i := 0; i < 2; i++ {
+		if err := InitDatabase(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d = DB(context.Background())
+	want := map[string][]string{"legacy": {"0"}, "indexed": {"1"}, "random": nil}
+	for name, values := range want {
+		var g Group
+		if err := d.Where("name = ?", name).Preload("PolicyParams").First(&g).Error; err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range g.PolicyParams {
+			got = append(got, p.Value)
+		}
+		if len(got) != len(values) || (len(got) > 0 && got[0] != values[0]) {
+			t.Fatalf("group %v: expected params %v, got %v", name, values, got)
+		}
+	}
+}
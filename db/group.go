@@ -14,8 +14,12 @@ type Group struct {
 	Name         string `gorm:"not null;unique;index"`
 	Policy       string `gorm:"not null"`
 	PolicyParams []GroupPolicyParam
+	// Filters add nodes matching them to the group when it is loaded.
+	Filters      []GroupFilter
 	Node         []Node         `gorm:"many2many:group_nodes;"`
 	Subscription []Subscription `gorm:"many2many:group_subscriptions;"`
+	// PinnedNodeID is the node that overrides the policy of the group, if any.
+	PinnedNodeID *uint
 
 	Version  uint `gorm:"not null;default:0"`
 	SystemID *uint
@@ -31,6 +35,27 @@ type GroupPolicyParam struct {
 	Group   Group
 }
 
+const (
+	GroupFilterKeyword         = "KEYWORD"
+	GroupFilterRegex           = "REGEX"
+	GroupFilterProtocol        = "PROTOCOL"
+	GroupFilterSubscriptionTag = "SUBSCRIPTION_TAG"
+	GroupFilterTagPrefix       = "TAG_PREFIX"
+)
+
+// GroupFilter is a rule of group membership. Nodes matching any filter join the group unless they match any
+// exclusion.
+type GroupFilter struct {
+	ID      uint   `gorm:"primaryKey;autoIncrement"`
+	Kind    string `gorm:"not null"`
+	Value   string `gorm:"not null"`
+	Exclude bool   `gorm:"not null;default:false"`
+
+	// Foreign keys.
+	GroupID uint
+	Group   Group
+}
+
 func (m *GroupPolicyParam) Marshal() *config_parser.Param {
 	return &config_parser.Param{
 		Key: m.Key,
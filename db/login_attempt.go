@@ -0,0 +1,19 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import "time"
+
+// LoginAttempt records a call to Query.token for later review.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	Username  string    `gorm:"not null;index"`
+	ClientIp  string    `gorm:"not null;default:''"`
+	UserAgent string    `gorm:"not null;default:''"`
+	Success   bool      `gorm:"not null"`
+	Reason    string    `gorm:"not null;default:''"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
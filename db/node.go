@@ -5,6 +5,8 @@
 
 package db
 
+import "time"
+
 type Node struct {
 	ID       uint   `gorm:"primaryKey;autoIncrement"`
 	Link     string `gorm:"not null"`
@@ -14,6 +16,10 @@ type Node struct {
 
 	Tag *string `gorm:"unique"`
 
+	// Latency is measured by the last test. Nil means the test failed or never ran.
+	Latency       *time.Duration
+	LastCheckedAt *time.Time
+
 	// Foreign keys.
 	// Nil SubscriptionID indicates nodes belonging to no subscription.
 	SubscriptionID *uint
@@ -0,0 +1,32 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import "time"
+
+// NodeHealthSample is the result of checks of a node. Samples are kept by links instead of node ids because
+// subscription updates import nodes again.
+type NodeHealthSample struct {
+	ID   uint      `gorm:"primaryKey;autoIncrement"`
+	Link string    `gorm:"not null;index:idx_node_health_samples_link_at"`
+	At   time.Time `gorm:"not null;index:idx_node_health_samples_link_at;index"`
+
+	Checks    uint `gorm:"not null"`
+	Successes uint `gorm:"not null"`
+	// Latency is the average latency of successful checks. Nil means no check succeeded.
+	Latency *time.Duration
+	// Merged is true if the sample is merged from samples in an hour.
+	Merged bool `gorm:"not null;default:false"`
+}
+
+// NodeHealthStat is computed from recent samples of a link.
+type NodeHealthStat struct {
+	Link string `gorm:"primaryKey"`
+	// Uptime is the percentage of successful checks.
+	Uptime     float64 `gorm:"not null"`
+	LatencyP50 *time.Duration
+	LatencyP95 *time.Duration
+}
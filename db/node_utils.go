@@ -25,9 +25,7 @@ func NewNodeModel(link string, tag *string, subscriptionId *uint) (*Node, error)
 		}
 		_tag = *tag
 	}
-	d, err := dialer.NewFromLink(&dialer.GlobalOption{
-		Log: logrus.StandardLogger(),
-	}, dialer.InstanceOption{DisableCheck: false}, link, _tag)
+	d, err := NewNodeDialer(link, _tag)
 	if err != nil {
 		return nil, err
 	}
@@ -43,3 +41,10 @@ func NewNodeModel(link string, tag *string, subscriptionId *uint) (*Node, error)
 		Subscription:   nil,
 	}, nil
 }
+
+// NewNodeDialer builds a dialer from the link of a node. Close it after use.
+func NewNodeDialer(link string, tag string) (*dialer.Dialer, error) {
+	return dialer.NewFromLink(&dialer.GlobalOption{
+		Log: logrus.StandardLogger(),
+	}, dialer.InstanceOption{DisableCheck: false}, link, tag)
+}
@@ -0,0 +1,33 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+// OidcConfig configures single sign-on through an OpenID Connect provider. There is at most one record.
+type OidcConfig struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	Issuer       string `gorm:"not null"`
+	ClientID     string `gorm:"not null"`
+	ClientSecret string `gorm:"not null;default:''"`
+	// Scopes are separated by spaces.
+	Scopes        string `gorm:"not null;default:'openid profile email'"`
+	UsernameClaim string `gorm:"not null;default:'preferred_username'"`
+	RoleClaim     string `gorm:"not null;default:'groups'"`
+	// RoleMapping is a json array of {"value": "claim value", "role": "admin"}.
+	RoleMapping string `gorm:"not null;default:'[]'"`
+	// DefaultRole is given to users matching no mapping. Empty means such users are rejected.
+	DefaultRole string `gorm:"not null;default:''"`
+}
+
+// OidcIdentity links a subject of the OpenID provider to a user.
+type OidcIdentity struct {
+	ID      uint   `gorm:"primaryKey;autoIncrement"`
+	Issuer  string `gorm:"not null;uniqueIndex:idx_oidc_identity"`
+	Subject string `gorm:"not null;uniqueIndex:idx_oidc_identity"`
+
+	// Foreign keys.
+	UserID uint `gorm:"not null;index"`
+	User   User
+}
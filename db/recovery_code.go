@@ -0,0 +1,16 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+// RecoveryCode is a one-time code to log in when the TOTP device is lost.
+type RecoveryCode struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement"`
+	CodeHash string `gorm:"not null;index"` // SHA-256 of the normalized code in hex.
+
+	// Foreign keys.
+	UserID uint `gorm:"not null;index"`
+	User   User
+}
@@ -0,0 +1,35 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import "time"
+
+const (
+	RevisionKindConfig  = "config"
+	RevisionKindDns     = "dns"
+	RevisionKindRouting = "routing"
+)
+
+// Revision is an immutable snapshot of a config, dns or routing, taken every time its content changes.
+type Revision struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement"`
+	Kind     string `gorm:"not null;index:idx_revision_target"`
+	TargetID uint   `gorm:"not null;index:idx_revision_target"`
+	// Version is the version of the target at this revision.
+	Version uint `gorm:"not null"`
+	// Content is the complete section, e.g. "routing { ... }".
+	Content   string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	// Author is empty if unknown, e.g. the baseline of records created before revisions were introduced.
+	AuthorID *uint
+	Author   string `gorm:"not null;default:''"`
+	// RestoredFromID is the revision this one was restored from.
+	RestoredFromID *uint
+	// TargetRemovedAt is when the target was removed. Revisions of removed targets are kept until purged, and
+	// TargetName keeps the name of the target to restore it with.
+	TargetRemovedAt *time.Time `gorm:"index"`
+	TargetName      string     `gorm:"not null;default:''"`
+}
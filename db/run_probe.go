@@ -0,0 +1,24 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import "time"
+
+const (
+	RunProbeKindHttp = "HTTP"
+	RunProbeKindDns  = "DNS"
+)
+
+// RunProbe is checked through the control plane after every run.
+type RunProbe struct {
+	ID   uint   `gorm:"primaryKey;autoIncrement"`
+	Kind string `gorm:"not null"`
+	// Target is the url to request for HTTP probes, or the domain to look up for DNS probes.
+	Target string `gorm:"not null"`
+	// Server is the dns server to query for DNS probes, like "1.1.1.1:53".
+	Server  string        `gorm:"not null;default:''"`
+	Timeout time.Duration `gorm:"not null"`
+}
@@ -0,0 +1,24 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package db
+
+import "time"
+
+// Session records an issued JWT. Removing the session revokes the token.
+type Session struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	Jti       string `gorm:"not null;unique;index"`
+	ClientIp  string `gorm:"not null;default:''"`
+	UserAgent string `gorm:"not null;default:''"`
+
+	CreatedAt  time.Time `gorm:"not null"`
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+
+	// Foreign keys.
+	UserID uint `gorm:"not null;index"`
+	User   User
+}
@@ -5,6 +5,8 @@
 
 package db
 
+import "time"
+
 type System struct {
 	ID                     uint   `gorm:"primaryKey;autoIncrement"`
 	Running                bool   `gorm:"not null;default:false"`
@@ -13,6 +15,15 @@ type System struct {
 	RunningRoutingVersion  uint   `gorm:"not null;default:0"`
 	RunningGroupVersionSum uint   `gorm:"not null;default:0"`
 	RunningGroupIds        string `gorm:"not null;default:''"`
+	// RunningSections is the json of sections rendered from the config loaded by the last run.
+	RunningSections string `gorm:"not null;default:''"`
+	// RunConfirmDeadline is set if the last run will be rolled back to RollbackState unless it is confirmed before.
+	RunConfirmDeadline *time.Time
+	RollbackState      string `gorm:"not null;default:''"`
+	// RollbackOnProbeFailure is whether to load the previous config back if any probe fails after a run.
+	RollbackOnProbeFailure bool `gorm:"not null;default:false"`
+	// AuditLogRetention is how long audit logs are kept. Zero means forever. Default to 90 days.
+	AuditLogRetention time.Duration `gorm:"not null;default:7776000000000000"`
 
 	// Foreign keys.
 	RunningConfigID  *uint
@@ -5,6 +5,14 @@
 
 package db
 
+import "fmt"
+
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
 type User struct {
 	ID           uint    `gorm:"primaryKey;autoIncrement"`
 	Username     string  `gorm:"unique;not null;index"`
@@ -13,4 +21,35 @@ type User struct {
 	JsonStorage  string  `gorm:"not null;default:'{}'"`
 	Avatar       *string `gorm:"null"`
 	Name         *string `gorm:"null"`
+	// Role is one of RoleAdmin, RoleOperator and RoleViewer.
+	// Users created before roles were introduced are admins.
+	Role string `gorm:"not null;default:'admin'"`
+
+	// TotpSecret is set on enrollment and takes effect once TotpEnabled.
+	TotpSecret  string `gorm:"not null;default:''"`
+	TotpEnabled bool   `gorm:"not null;default:false"`
+	// TotpLastCounter is the time step of the last accepted code, used to prevent replay.
+	TotpLastCounter uint64 `gorm:"not null;default:0"`
+}
+
+var roleLevel = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+func ValidateRole(role string) error {
+	if _, ok := roleLevel[role]; !ok {
+		return fmt.Errorf("unknown role: %v", role)
+	}
+	return nil
+}
+
+// RoleSatisfies reports whether role is at least as privileged as required.
+func RoleSatisfies(role string, required string) bool {
+	level, ok := roleLevel[role]
+	if !ok {
+		return false
+	}
+	return level >= roleLevel[required]
 }
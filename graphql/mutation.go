@@ -15,30 +15,95 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
 	"github.com/daeuniverse/dae-wing/graphql/internal"
+	"github.com/daeuniverse/dae-wing/graphql/scalar"
+	"github.com/daeuniverse/dae-wing/graphql/service/apitoken"
+	"github.com/daeuniverse/dae-wing/graphql/service/audit"
 	"github.com/daeuniverse/dae-wing/graphql/service/config"
 	"github.com/daeuniverse/dae-wing/graphql/service/config/global"
 	"github.com/daeuniverse/dae-wing/graphql/service/dns"
 	"github.com/daeuniverse/dae-wing/graphql/service/group"
+	"github.com/daeuniverse/dae-wing/graphql/service/importer"
+	"github.com/daeuniverse/dae-wing/graphql/service/login"
+	"github.com/daeuniverse/dae-wing/graphql/service/logs"
 	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae-wing/graphql/service/probe"
+	"github.com/daeuniverse/dae-wing/graphql/service/revision"
 	"github.com/daeuniverse/dae-wing/graphql/service/routing"
+	"github.com/daeuniverse/dae-wing/graphql/service/session"
 	"github.com/daeuniverse/dae-wing/graphql/service/subscription"
+	"github.com/daeuniverse/dae-wing/graphql/service/user"
 	"github.com/daeuniverse/dae/pkg/config_parser"
 	"github.com/graph-gophers/graphql-go"
 	"github.com/tidwall/sjson"
+	"gorm.io/gorm"
 )
 
 type MutationResolver struct{}
 
-func (r *MutationResolver) CreateUser(args *struct {
+func validatePassword(password string) error {
+	if len(password) < 6 || strings.IndexFunc(password, unicode.IsLetter) < 0 || strings.IndexFunc(password, unicode.IsNumber) < 0 {
+		return fmt.Errorf("too weak password; should contain numbers and letters, and no less than 6 in length")
+	}
+	return nil
+}
+
+// randomHex returns n random bytes in hex.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func createUser(d *gorm.DB, username string, password string, role string) (m *db.User, err error) {
+	if err = validatePassword(password); err != nil {
+		return nil, err
+	}
+	return insertUser(d, username, password, role)
+}
+
+// createSsoUser creates a user signing in by single sign-on, who has an unknown random password.
+func createSsoUser(d *gorm.DB, username string, role string) (m *db.User, err error) {
+	password, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	return insertUser(d, username, password, role)
+}
+
+// insertUser creates a user without checking the password.
+func insertUser(d *gorm.DB, username string, password string, role string) (m *db.User, err error) {
+	// Generate jwt secret and hash password.
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	// Create user.
+	m = &db.User{
+		Username:     username,
+		PasswordHash: hashedPassword,
+		JwtSecret:    secret,
+		Role:         role,
+	}
+	if err = d.Model(&db.User{}).Create(m).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *MutationResolver) CreateUser(ctx context.Context, args *struct {
 	Username string
 	Password string
 }) (token string, err error) {
-	if len(args.Password) < 6 || strings.IndexFunc(args.Password, unicode.IsLetter) < 0 || strings.IndexFunc(args.Password, unicode.IsNumber) < 0 {
-		return "", fmt.Errorf("too weak password; should contain numbers and letters, and no less than 6 in length")
-	}
-	tx := db.BeginTx(context.TODO())
+	tx := db.BeginTx(ctx)
 	defer func() {
 		if err == nil {
 			tx.Commit()
@@ -54,27 +119,98 @@ func (r *MutationResolver) CreateUser(args *struct {
 	if n > 0 {
 		return "", fmt.Errorf("a user already exists")
 	}
-	// Hash password.
-	var sec [32]byte
-	if _, err = io.ReadFull(rand.Reader, sec[:]); err != nil {
+	// The first user is always an admin.
+	if _, err = createUser(tx, args.Username, args.Password, db.RoleAdmin); err != nil {
 		return "", err
 	}
-	secret := hex.EncodeToString(sec[:])
-	hashedPassword, err := hashPassword([]byte(secret), args.Password)
+	// Return token.
+	return getToken(ctx, tx, args.Username, args.Password, nil)
+}
+
+func (r *MutationResolver) AddUser(args *struct {
+	Username string
+	Password string
+	Role     string
+}) (u *user.Resolver, err error) {
+	role := strings.ToLower(args.Role)
+	if err = db.ValidateRole(role); err != nil {
+		return nil, err
+	}
+	m, err := createUser(db.DB(context.TODO()), args.Username, args.Password, role)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	// Create user.
-	if err = tx.Model(&db.User{}).Create(&db.User{
-		Username:     args.Username,
-		PasswordHash: hashedPassword,
-		JwtSecret:    secret,
-	}).Error; err != nil {
+	return &user.Resolver{User: m}, nil
+}
+
+func (r *MutationResolver) RemoveUser(ctx context.Context, args *struct {
+	ID graphql.ID
+}) (int32, error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return user.Remove(ctx, u, args.ID)
+}
+
+func (r *MutationResolver) SetUserRole(ctx context.Context, args *struct {
+	ID   graphql.ID
+	Role string
+}) (int32, error) {
+	return user.SetRole(ctx, args.ID, strings.ToLower(args.Role))
+}
+
+func (r *MutationResolver) CreateApiToken(ctx context.Context, args *struct {
+	Name      string
+	Scope     string
+	ExpiresAt *graphql.Time
+}) (string, error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
 		return "", err
 	}
-	// Return token.
-	return getToken(tx, args.Username, args.Password)
+	return apitoken.Create(ctx, u, args.Name, strings.ToLower(args.Scope), args.ExpiresAt)
 }
+
+func (r *MutationResolver) RevokeApiToken(ctx context.Context, args *struct {
+	ID graphql.ID
+}) (int32, error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return apitoken.Revoke(ctx, u, args.ID)
+}
+
+func (r *MutationResolver) RevokeSession(ctx context.Context, args *struct {
+	ID graphql.ID
+}) (int32, error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return session.Revoke(ctx, u, args.ID)
+}
+
+func (r *MutationResolver) UnlockLogin(args *struct {
+	Kind string
+	Key  string
+}) int32 {
+	if login.DefaultLimiter.Unlock(strings.ToLower(args.Kind), args.Key) {
+		return 1
+	}
+	return 0
+}
+
+func (r *MutationResolver) SetAuditLogRetention(ctx context.Context, args *struct {
+	Retention scalar.Duration
+}) (int32, error) {
+	if err := audit.SetRetention(ctx, args.Retention.Duration); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
 func (r *MutationResolver) SetJsonStorage(ctx context.Context, args *struct {
 	Paths  []string
 	Values []string
@@ -167,11 +303,11 @@ func UpdatePassword(ctx context.Context, args *struct {
 }, u *db.User, skipVerify bool) (token string, err error) {
 	// Check password.
 	if !skipVerify {
-		hashedPassword, err := hashPassword([]byte(u.JwtSecret), args.CurrentPassword)
+		ok, _, err := verifyPassword(u, args.CurrentPassword)
 		if err != nil {
 			return "", err
 		}
-		if hashedPassword != u.PasswordHash {
+		if !ok {
 			return "", fmt.Errorf("incorrect password")
 		}
 	}
@@ -182,7 +318,7 @@ func UpdatePassword(ctx context.Context, args *struct {
 		return "", err
 	}
 	secret := hex.EncodeToString(sec[:])
-	hashedPassword, err := hashPassword([]byte(secret), args.NewPassword)
+	hashedPassword, err := hashPassword(args.NewPassword)
 	if err != nil {
 		return "", err
 	}
@@ -201,9 +337,15 @@ func UpdatePassword(ctx context.Context, args *struct {
 	if q.Error != nil {
 		return "", q.Error
 	}
+	u.PasswordHash = hashedPassword
+	u.JwtSecret = secret
+	// Tokens signed by the old secret are no longer valid.
+	if err = session.RevokeAll(tx, u.ID); err != nil {
+		return "", err
+	}
 
-	// Return token.
-	return getToken(tx, u.Username, args.NewPassword)
+	// Return token. The password has been verified, so no second factor is demanded.
+	return issueToken(ctx, tx, u)
 }
 
 func (r *MutationResolver) UpdatePassword(ctx context.Context, args *struct {
@@ -216,7 +358,7 @@ func (r *MutationResolver) UpdatePassword(ctx context.Context, args *struct {
 	}
 	return UpdatePassword(ctx, args, u, false)
 }
-func (r *MutationResolver) CreateConfig(args *struct {
+func (r *MutationResolver) CreateConfig(ctx context.Context, args *struct {
 	Name   *string
 	Global *global.Input
 }) (c *config.Resolver, err error) {
@@ -224,14 +366,14 @@ func (r *MutationResolver) CreateConfig(args *struct {
 	if args.Name != nil {
 		strName = *args.Name
 	}
-	return config.Create(context.TODO(), strName, args.Global)
+	return config.Create(ctx, strName, args.Global)
 }
 
-func (r *MutationResolver) UpdateConfig(args *struct {
+func (r *MutationResolver) UpdateConfig(ctx context.Context, args *struct {
 	ID     graphql.ID
 	Global global.Input
 }) (*config.Resolver, error) {
-	return config.Update(context.TODO(), args.ID, args.Global)
+	return config.Update(ctx, args.ID, args.Global)
 }
 
 func (r *MutationResolver) RenameConfig(args *struct {
@@ -254,19 +396,73 @@ func (r *MutationResolver) SelectConfig(args *struct {
 }
 
 func (r *MutationResolver) Run(args *struct {
-	Dry bool
+	Dry            bool
+	ConfirmTimeout *scalar.Duration
 }) (int32, error) {
-	tx := db.BeginTx(context.TODO())
-	ret, err := config.Run(tx, args.Dry)
+	var confirmTimeout time.Duration
+	if args.ConfirmTimeout != nil {
+		if args.ConfirmTimeout.Duration <= 0 {
+			return 0, fmt.Errorf("confirmTimeout should be positive")
+		}
+		confirmTimeout = args.ConfirmTimeout.Duration
+	}
+	return config.Run(context.TODO(), args.Dry, confirmTimeout)
+}
+
+func (r *MutationResolver) ConfirmRun() (int32, error) {
+	return config.ConfirmRun(context.TODO())
+}
+
+func (r *MutationResolver) SetRunProbes(ctx context.Context, args *struct {
+	Probes            []*probe.Input
+	RollbackOnFailure bool
+}) (int32, error) {
+	return probe.Set(ctx, args.Probes, args.RollbackOnFailure)
+}
+
+func (r *MutationResolver) SetLogLevel(args *struct {
+	Level    string
+	Duration *scalar.Duration
+}) (int32, error) {
+	level, err := logs.ParseLevel(args.Level)
 	if err != nil {
-		tx.Rollback()
 		return 0, err
 	}
-	tx.Commit()
-	return ret, nil
+	var duration time.Duration
+	if args.Duration != nil {
+		if args.Duration.Duration <= 0 {
+			return 0, fmt.Errorf("duration should be positive")
+		}
+		duration = args.Duration.Duration
+	}
+	dae.SetLogLevel(level, duration)
+	return 1, nil
+}
+
+func (r *MutationResolver) TestNodes(ctx context.Context, args *struct {
+	IDs     []graphql.ID
+	Url     *string
+	Timeout *scalar.Duration
+}) ([]*node.TestResult, error) {
+	rawUrl := node.DefaultTestUrl
+	if args.Url != nil {
+		rawUrl = *args.Url
+	}
+	u, err := node.ParseTestUrl(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	timeout := node.DefaultTestTimeout
+	if args.Timeout != nil {
+		if args.Timeout.Duration <= 0 || args.Timeout.Duration > node.MaxTestTimeout {
+			return nil, fmt.Errorf("timeout should be positive and at most %v", node.MaxTestTimeout)
+		}
+		timeout = args.Timeout.Duration
+	}
+	return node.Test(ctx, args.IDs, u, timeout)
 }
 
-func (r *MutationResolver) CreateDns(args *struct {
+func (r *MutationResolver) CreateDns(ctx context.Context, args *struct {
 	Name *string
 	Dns  *string
 }) (c *dns.Resolver, err error) {
@@ -277,14 +473,35 @@ func (r *MutationResolver) CreateDns(args *struct {
 	if args.Name != nil {
 		strName = *args.Name
 	}
-	return dns.Create(context.TODO(), strName, strDns)
+	return dns.Create(ctx, strName, strDns)
 }
 
-func (r *MutationResolver) UpdateDns(args *struct {
+func (r *MutationResolver) ImportDaeConfig(ctx context.Context, args *struct {
+	Raw           string
+	Name          *string
+	RollbackError *bool
+}) (*importer.Result, error) {
+	var strName string
+	if args.Name != nil {
+		strName = *args.Name
+	}
+	result, err := importer.Import(ctx, strName, args.Raw, args.RollbackError != nil && *args.RollbackError)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Subscriptions) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		subscription.UpdateAll(ctx)
+	}
+	return result, nil
+}
+
+func (r *MutationResolver) UpdateDns(ctx context.Context, args *struct {
 	ID  graphql.ID
 	Dns string
 }) (*dns.Resolver, error) {
-	return dns.Update(context.TODO(), args.ID, args.Dns)
+	return dns.Update(ctx, args.ID, args.Dns)
 }
 
 func (r *MutationResolver) RenameDns(args *struct {
@@ -306,7 +523,7 @@ func (r *MutationResolver) SelectDns(args *struct {
 	return dns.Select(context.TODO(), args.ID)
 }
 
-func (r *MutationResolver) CreateRouting(args *struct {
+func (r *MutationResolver) CreateRouting(ctx context.Context, args *struct {
 	Name    *string
 	Routing *string
 }) (c *routing.Resolver, err error) {
@@ -317,14 +534,14 @@ func (r *MutationResolver) CreateRouting(args *struct {
 	if args.Name != nil {
 		strName = *args.Name
 	}
-	return routing.Create(context.TODO(), strName, strRouting)
+	return routing.Create(ctx, strName, strRouting)
 }
 
-func (r *MutationResolver) UpdateRouting(args *struct {
+func (r *MutationResolver) UpdateRouting(ctx context.Context, args *struct {
 	ID      graphql.ID
 	Routing string
 }) (*routing.Resolver, error) {
-	return routing.Update(context.TODO(), args.ID, args.Routing)
+	return routing.Update(ctx, args.ID, args.Routing)
 }
 
 func (r *MutationResolver) RenameRouting(args *struct {
@@ -346,6 +563,35 @@ func (r *MutationResolver) SelectRouting(args *struct {
 	return routing.Select(context.TODO(), args.ID)
 }
 
+func (r *MutationResolver) RestoreRevision(ctx context.Context, args *struct {
+	ID graphql.ID
+}) (*revision.Resolver, error) {
+	rev, err := revision.Get(ctx, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch rev.Kind {
+	case db.RevisionKindConfig:
+		rev, err = revision.Restore(ctx, rev, config.RevisionTarget)
+	case db.RevisionKindDns:
+		rev, err = revision.Restore(ctx, rev, dns.RevisionTarget)
+	case db.RevisionKindRouting:
+		rev, err = revision.Restore(ctx, rev, routing.RevisionTarget)
+	default:
+		return nil, fmt.Errorf("unexpected revision kind: %v", rev.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision.Resolver{Revision: rev}, nil
+}
+
+func (r *MutationResolver) PurgeRevisions(ctx context.Context, args *struct {
+	IDs []graphql.ID
+}) (int32, error) {
+	return revision.Purge(ctx, args.IDs)
+}
+
 func (r *MutationResolver) ImportNodes(args *struct {
 	RollbackError bool
 	Args          []*internal.ImportArgument
@@ -391,8 +637,12 @@ func (r *MutationResolver) ImportSubscription(args *struct {
 	RollbackError bool
 	Arg           internal.ImportArgument
 }) (*subscription.ImportResult, error) {
+	links, err := subscription.Fetch(&args.Arg)
+	if err != nil {
+		return nil, err
+	}
 	tx := db.BeginTx(context.TODO())
-	result, err := subscription.Import(tx, args.RollbackError, &args.Arg)
+	result, err := subscription.ImportLinks(tx, args.RollbackError, &args.Arg, links)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
@@ -492,6 +742,26 @@ func (r *MutationResolver) GroupSetPolicy(args *struct {
 	return group.SetPolicy(context.TODO(), args.ID, args.Policy, policyParams)
 }
 
+func (r *MutationResolver) GroupSetFilters(args *struct {
+	ID      graphql.ID
+	Filters []group.FilterInput
+}) (int32, error) {
+	return group.SetFilters(context.TODO(), args.ID, args.Filters)
+}
+
+func (r *MutationResolver) PinGroupNode(args *struct {
+	GroupId graphql.ID
+	NodeId  graphql.ID
+}) (int32, error) {
+	return group.Pin(context.TODO(), args.GroupId, args.NodeId)
+}
+
+func (r *MutationResolver) UnpinGroup(args *struct {
+	GroupId graphql.ID
+}) (int32, error) {
+	return group.Unpin(context.TODO(), args.GroupId)
+}
+
 func (r *MutationResolver) RemoveGroup(args *struct {
 	ID graphql.ID
 }) (int32, error) {
@@ -0,0 +1,162 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/login"
+	"github.com/daeuniverse/dae-wing/graphql/service/oidc"
+	"github.com/daeuniverse/dae-wing/graphql/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// userFromIdentity returns the user linked to the identity, creating one on first sign-in.
+// The role of the user follows the role mapping on every sign-in.
+func userFromIdentity(d *gorm.DB, identity *oidc.Identity) (*db.User, error) {
+	var link db.OidcIdentity
+	err := d.Model(&db.OidcIdentity{}).
+		Where("issuer = ?", identity.Issuer).
+		Where("subject = ?", identity.Subject).
+		Preload("User").
+		First(&link).Error
+	switch {
+	case err == nil:
+		if link.User.Role != identity.Role {
+			if link.User.Role == db.RoleAdmin {
+				if err = user.EnsureOtherAdmin(d, link.UserID); err != nil {
+					// Keep the last admin rather than locking everyone out.
+					logrus.WithField("user", link.User.Username).Warnf("Role mapping of single sign-on is not applied: %v", err)
+					return &link.User, nil
+				}
+			}
+			if err = d.Model(&db.User{ID: link.UserID}).Update("role", identity.Role).Error; err != nil {
+				return nil, err
+			}
+			link.User.Role = identity.Role
+		}
+		return &link.User, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+	// Do not take over an existing local user.
+	var cnt int64
+	if err = d.Model(&db.User{}).Where("username = ?", identity.Username).Count(&cnt).Error; err != nil {
+		return nil, err
+	}
+	if cnt > 0 {
+		return nil, fmt.Errorf("username %v is taken by another user", identity.Username)
+	}
+	m, err := createSsoUser(d, identity.Username, identity.Role)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.Create(&db.OidcIdentity{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		UserID:  m.ID,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *queryResolver) OidcEnabled(ctx context.Context) (bool, error) {
+	c, err := oidc.GetConfig(db.DB(ctx))
+	if err != nil {
+		return false, err
+	}
+	return c != nil, nil
+}
+
+func (r *queryResolver) OidcConfig(ctx context.Context) (*oidc.Resolver, error) {
+	c, err := oidc.GetConfig(db.DB(ctx))
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return &oidc.Resolver{OidcConfig: c}, nil
+}
+
+func (r *queryResolver) OidcAuthorizeUrl(ctx context.Context, args *struct {
+	RedirectUri string
+}) (string, error) {
+	return oidc.AuthorizeUrl(ctx, args.RedirectUri)
+}
+
+func (r *queryResolver) OidcToken(ctx context.Context, args *struct {
+	Code  string
+	State string
+	Otp   *string
+}) (string, error) {
+	identity, err := oidc.Authenticate(ctx, args.Code, args.State)
+	if err != nil {
+		return "", err
+	}
+	clientIp, _ := ctx.Value("clientIp").(string)
+	userAgent, _ := ctx.Value("userAgent").(string)
+	token, username, reason, err := oidcSignIn(ctx, identity, args.Otp)
+	if errors.Is(err, ErrSecondFactorRequired) || errors.Is(err, ErrIncorrectSecondCode) {
+		// Let the client retry with the same code and state instead of signing in again.
+		oidc.Hold(args.Code, args.State, identity)
+	}
+	if username != "" && (err == nil || reason != "") {
+		if e := login.Record(db.DB(ctx), username, clientIp, userAgent, err == nil, reason); e != nil {
+			logrus.WithError(e).Warnln("Failed to record login attempt")
+		}
+	}
+	return token, err
+}
+
+// oidcSignIn issues a token for the user linked to the identity. Single sign-on replaces the password but not the
+// second factor, which is throttled the same way as the token query. The reason is set if the attempt should be
+// recorded as failed.
+func oidcSignIn(ctx context.Context, identity *oidc.Identity, otp *string) (token string, username string, reason string, err error) {
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	u, err := userFromIdentity(tx, identity)
+	if err != nil {
+		return "", "", "", err
+	}
+	if u.TotpEnabled {
+		clientIp, _ := ctx.Value("clientIp").(string)
+		if err = login.DefaultLimiter.Allow(clientIp, u.Username); err != nil {
+			return "", u.Username, login.ReasonThrottled, err
+		}
+		err = verifySecondFactor(tx, u, otp)
+		switch {
+		case err == nil:
+			login.DefaultLimiter.Succeed(clientIp, u.Username)
+		case errors.Is(err, ErrIncorrectSecondCode):
+			login.DefaultLimiter.Fail(clientIp, u.Username)
+			return "", u.Username, login.ReasonIncorrectSecondFactor, err
+		default:
+			// A missing second factor or internal errors are not counted.
+			login.DefaultLimiter.Release(clientIp, u.Username)
+			return "", u.Username, "", err
+		}
+	}
+	token, err = issueToken(ctx, tx, u)
+	return token, u.Username, "", err
+}
+
+func (r *MutationResolver) SetOidcConfig(ctx context.Context, args *struct {
+	Config *oidc.ConfigInput
+}) (int32, error) {
+	if err := oidc.SetConfig(ctx, args.Config); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,108 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphql
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/sha3"
+)
+
+// Argon2id parameters. They follow the OWASP minimum recommendation, which is
+// affordable on routers. Hashes with other parameters are upgraded on login.
+const (
+	argon2Memory  = 19 * 1024 // KiB
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+var b64 = base64.RawStdEncoding
+
+// hashPassword hashes the password with argon2id and encodes it in the PHC string format:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+	hash := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%v$%v",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		b64.EncodeToString(salt), b64.EncodeToString(hash),
+	), nil
+}
+
+// legacyHashPassword is the single-pass SHAKE256 hash salted with the jwt secret.
+// It is only used to verify passwords set before argon2id was introduced.
+func legacyHashPassword(salt []byte, password string) (string, error) {
+	h := sha3.NewShake256()
+	_, err := h.Write(salt)
+	if err != nil {
+		return "", err
+	}
+	_, err = h.Write([]byte(password))
+	if err != nil {
+		return "", err
+	}
+	var hash [32]byte
+	_, err = io.ReadFull(h, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// verifyPassword checks the password against the user's hash. needRehash is true if the password is correct but
+// the hash is in legacy format or uses outdated parameters.
+func verifyPassword(u *db.User, password string) (ok bool, needRehash bool, err error) {
+	if !strings.HasPrefix(u.PasswordHash, "$") {
+		hashedPassword, err := legacyHashPassword([]byte(u.JwtSecret), password)
+		if err != nil {
+			return false, false, err
+		}
+		ok = subtle.ConstantTimeCompare([]byte(hashedPassword), []byte(u.PasswordHash)) == 1
+		return ok, ok, nil
+	}
+	// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+	fields := strings.Split(u.PasswordHash, "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		return false, false, fmt.Errorf("unsupported password hash format")
+	}
+	var version int
+	if _, err = fmt.Sscanf(fields[2], "v=%d", &version); err != nil {
+		return false, false, fmt.Errorf("bad password hash: %w", err)
+	}
+	if version != argon2.Version {
+		return false, false, fmt.Errorf("unsupported argon2 version: %v", version)
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err = fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, fmt.Errorf("bad password hash: %w", err)
+	}
+	salt, err := b64.DecodeString(fields[4])
+	if err != nil {
+		return false, false, fmt.Errorf("bad password hash: %w", err)
+	}
+	hash, err := b64.DecodeString(fields[5])
+	if err != nil {
+		return false, false, fmt.Errorf("bad password hash: %w", err)
+	}
+	computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(hash)))
+	ok = subtle.ConstantTimeCompare(computed, hash) == 1
+	needRehash = ok && (memory != argon2Memory || time != argon2Time || threads != argon2Threads || len(hash) != argon2KeyLen)
+	return ok, needRehash, nil
+}
@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/pkg/totp"
+)
+
+func TestGetTokenRehashesAfterSecondFactor(t *testing.T) {
+	if err := db.InitDatabase(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	d := db.DB(ctx)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyHash, err := legacyHashPassword([]byte("jwt-secret"), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := db.User{
+		Username:     "alice",
+		PasswordHash: legacyHash,
+		JwtSecret:    "jwt-secret",
+		Role:         db.RoleAdmin,
+		TotpSecret:   secret,
+		TotpEnabled:  true,
+	}
+	if err = d.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	passwordHash := func() string {
+		var m db.User
+		if err := d.Model(&db.User{}).Where("id = ?", u.ID).First(&m).Error; err != nil {
+			t.Fatal(err)
+		}
+		return m.PasswordHash
+	}
+
+	wrong := "000000"
+	if code, _ := totp.CodeAt(secret, totp.Counter(time.Now())); code == wrong {
+		wrong = "111111"
+	}
+	if _, err = getToken(ctx, d, "alice", "password", nil); !errors.Is(err, ErrSecondFactorRequired) {
+		t.Fatalf("expected the second factor to be required, got %v", err)
+	}
+	if _, err = getToken(ctx, d, "alice", "password", &wrong); !errors.Is(err, ErrIncorrectSecondCode) {
+		t.Fatalf("expected an incorrect second code, got %v", err)
+	}
+	if passwordHash() != legacyHash {
+		t.Fatal("the password hash changed before the second factor was verified")
+	}
+
+	code, err := totp.CodeAt(secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = getToken(ctx, d, "alice", "password", &code); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(passwordHash(), "$argon2id$") {
+		t.Fatalf("expected the password hash to be upgraded, got %v", passwordHash())
+	}
+}
@@ -7,88 +7,149 @@ package graphql
 
 import (
 	"context"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
+	"strings"
 	"time"
 
 	"github.com/daeuniverse/dae-wing/common"
 	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/scalar"
+	"github.com/daeuniverse/dae-wing/graphql/service/apitoken"
+	"github.com/daeuniverse/dae-wing/graphql/service/audit"
 	"github.com/daeuniverse/dae-wing/graphql/service/config"
 	"github.com/daeuniverse/dae-wing/graphql/service/dns"
 	"github.com/daeuniverse/dae-wing/graphql/service/general"
 	"github.com/daeuniverse/dae-wing/graphql/service/group"
+	"github.com/daeuniverse/dae-wing/graphql/service/login"
+	"github.com/daeuniverse/dae-wing/graphql/service/logs"
 	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae-wing/graphql/service/probe"
+	"github.com/daeuniverse/dae-wing/graphql/service/revision"
 	"github.com/daeuniverse/dae-wing/graphql/service/routing"
+	"github.com/daeuniverse/dae-wing/graphql/service/session"
 	"github.com/daeuniverse/dae-wing/graphql/service/subscription"
 	"github.com/daeuniverse/dae-wing/graphql/service/user"
 	daeConfig "github.com/daeuniverse/dae/config"
 	"github.com/daeuniverse/dae/pkg/config_parser"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/graph-gophers/graphql-go"
+	"github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
-	"golang.org/x/crypto/sha3"
 	"gorm.io/gorm"
 )
 
 type queryResolver struct{}
 
+var errIncorrectCredentials = fmt.Errorf("incorrect username or password")
+
 func (r *queryResolver) HealthCheck() int32 {
 	return 1
 }
-func hashPassword(salt []byte, password string) (string, error) {
-	h := sha3.NewShake256()
-	_, err := h.Write(salt)
-	if err != nil {
-		return "", err
-	}
-	_, err = h.Write([]byte(password))
-	if err != nil {
-		return "", err
-	}
-	var hash [32]byte
-	_, err = io.ReadFull(h, hash[:])
-	if err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(hash[:]), nil
-}
 func getToken(
+	ctx context.Context,
 	d *gorm.DB,
 	username string,
-	password string) (string, error) {
+	password string,
+	otp *string) (string, error) {
 	var m db.User
 	// Check username.
 	q := d.Model(&db.User{}).Where("username = ?", username).First(&m)
 	if q.Error != nil || q.RowsAffected == 0 {
-		return "", fmt.Errorf("incorrect username or password")
+		return "", errIncorrectCredentials
 	}
 	// Check password.
-	hashedPassword, err := hashPassword([]byte(m.JwtSecret), password)
+	ok, needRehash, err := verifyPassword(&m, password)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errIncorrectCredentials
+	}
+	// Check the second factor.
+	if err = verifySecondFactor(d, &m, otp); err != nil {
+		return "", err
+	}
+	token, err := issueToken(ctx, d, &m)
 	if err != nil {
 		return "", err
 	}
-	if hashedPassword != m.PasswordHash {
-		return "", fmt.Errorf("incorrect username or password")
+	if needRehash {
+		// Transparently upgrade the legacy or outdated hash once the login succeeds. The old hash keeps working if it
+		// fails, so it is tried again at the next login.
+		if err = rehashPassword(d, m.ID, password); err != nil {
+			logrus.WithError(err).Warnln("Failed to upgrade the password hash")
+		}
+	}
+	return token, nil
+}
+
+func rehashPassword(d *gorm.DB, userId uint, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return d.Model(&db.User{ID: userId}).Update("password_hash", hashedPassword).Error
+}
+
+// issueToken records a new session for the authenticated user and files a token for it.
+func issueToken(ctx context.Context, d *gorm.DB, m *db.User) (string, error) {
+	// Record the session.
+	expiresAt := time.Now().Add(30 * time.Hour * 24)
+	clientIp, _ := ctx.Value("clientIp").(string)
+	userAgent, _ := ctx.Value("userAgent").(string)
+	jti, err := session.Create(d, m.ID, clientIp, userAgent, expiresAt)
+	if err != nil {
+		return "", err
 	}
 
 	// File a token.
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"role": "admin",
+		"role": m.Role,
 		"sub":  m.Username,
-		"exp":  time.Now().Add(30 * time.Hour * 24).UTC().Unix(),
+		"jti":  jti,
+		"exp":  expiresAt.UTC().Unix(),
 	})
 	// Sign and get the complete encoded token as a string using the secret
 	return token.SignedString([]byte(m.JwtSecret))
 }
 
-func (r *queryResolver) Token(args *struct {
+func (r *queryResolver) Token(ctx context.Context, args *struct {
 	Username string
 	Password string
+	Otp      *string
 }) (string, error) {
-	return getToken(db.DB(context.TODO()), args.Username, args.Password)
+	clientIp, _ := ctx.Value("clientIp").(string)
+	userAgent, _ := ctx.Value("userAgent").(string)
+	d := db.DB(ctx)
+	if err := login.DefaultLimiter.Allow(clientIp, args.Username); err != nil {
+		if e := login.Record(d, args.Username, clientIp, userAgent, false, login.ReasonThrottled); e != nil {
+			logrus.WithError(e).Warnln("Failed to record login attempt")
+		}
+		return "", err
+	}
+	token, err := getToken(ctx, d, args.Username, args.Password, args.Otp)
+	var reason string
+	switch {
+	case err == nil:
+		login.DefaultLimiter.Succeed(clientIp, args.Username)
+	case errors.Is(err, errIncorrectCredentials):
+		reason = login.ReasonIncorrectCredentials
+	case errors.Is(err, ErrIncorrectSecondCode):
+		reason = login.ReasonIncorrectSecondFactor
+	default:
+		// Missing second factor or internal errors are not counted.
+		login.DefaultLimiter.Release(clientIp, args.Username)
+		return "", err
+	}
+	if reason != "" {
+		login.DefaultLimiter.Fail(clientIp, args.Username)
+	}
+	if e := login.Record(d, args.Username, clientIp, userAgent, err == nil, reason); e != nil {
+		logrus.WithError(e).Warnln("Failed to record login attempt")
+	}
+	return token, err
 }
 func numberUsers(d *gorm.DB) (int32, error) {
 	var cnt int64
@@ -138,6 +199,105 @@ func (r *queryResolver) User(ctx context.Context) (*user.Resolver, error) {
 	return &user.Resolver{User: u}, nil
 }
 
+func (r *queryResolver) Users() (rs []*user.Resolver, err error) {
+	var models []db.User
+	if err = db.DB(context.TODO()).Model(&db.User{}).Order("id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	for _, _m := range models {
+		m := _m
+		rs = append(rs, &user.Resolver{User: &m})
+	}
+	return rs, nil
+}
+
+func (r *queryResolver) ApiTokens(ctx context.Context) (rs []*apitoken.Resolver, err error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var models []db.ApiToken
+	if err = db.DB(ctx).Model(&db.ApiToken{}).Where("user_id = ?", u.ID).Order("id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	for _, _m := range models {
+		m := _m
+		rs = append(rs, &apitoken.Resolver{ApiToken: &m})
+	}
+	return rs, nil
+}
+
+func (r *queryResolver) Sessions(ctx context.Context) (rs []*session.Resolver, err error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var models []db.Session
+	if err = db.DB(ctx).Model(&db.Session{}).
+		Where("user_id = ?", u.ID).
+		Where("expires_at > ?", time.Now()).
+		Order("last_seen_at DESC").
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+	currentJti, _ := ctx.Value("jti").(string)
+	for _, _m := range models {
+		m := _m
+		rs = append(rs, &session.Resolver{Session: &m, CurrentJti: currentJti})
+	}
+	return rs, nil
+}
+
+func (r *queryResolver) LoginAttempts(args *struct {
+	Username *string
+	Success  *bool
+	First    *int32
+}) (rs []*login.AttemptResolver, err error) {
+	q := db.DB(context.TODO()).Model(&db.LoginAttempt{})
+	if args.Username != nil {
+		q = q.Where("username = ?", *args.Username)
+	}
+	if args.Success != nil {
+		q = q.Where("success = ?", *args.Success)
+	}
+	limit := 50
+	if args.First != nil {
+		limit = int(*args.First)
+	}
+	var models []db.LoginAttempt
+	if err = q.Order("id DESC").Limit(limit).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	for _, _m := range models {
+		m := _m
+		rs = append(rs, &login.AttemptResolver{LoginAttempt: &m})
+	}
+	return rs, nil
+}
+
+func (r *queryResolver) LoginLockouts() (rs []*login.LockoutResolver) {
+	for _, l := range login.DefaultLimiter.Lockouts() {
+		rs = append(rs, &login.LockoutResolver{Lockout: l})
+	}
+	return rs
+}
+
+func (r *queryResolver) AuditLog(args *struct {
+	First  *int32
+	After  *graphql.ID
+	Filter *audit.Filter
+}) (*audit.ConnectionResolver, error) {
+	return audit.NewConnectionResolver(args.First, args.After, args.Filter)
+}
+
+func (r *queryResolver) AuditLogRetention(ctx context.Context) (scalar.Duration, error) {
+	retention, err := audit.Retention(ctx)
+	if err != nil {
+		return scalar.Duration{}, err
+	}
+	return scalar.Duration{Duration: retention}, nil
+}
+
 func (r *queryResolver) General() (*general.Resolver, error) {
 	schema, err := SchemaString()
 	if err != nil {
@@ -255,6 +415,133 @@ func (r *queryResolver) Routings(args *struct {
 	return rs, nil
 }
 
+func (r *queryResolver) ConfigRevisions(ctx context.Context, args *struct {
+	ID    graphql.ID
+	First *int32
+	After *graphql.ID
+}) ([]*revision.Resolver, error) {
+	return revision.List(ctx, db.RevisionKindConfig, args.ID, args.First, args.After)
+}
+
+func (r *queryResolver) DnsRevisions(ctx context.Context, args *struct {
+	ID    graphql.ID
+	First *int32
+	After *graphql.ID
+}) ([]*revision.Resolver, error) {
+	return revision.List(ctx, db.RevisionKindDns, args.ID, args.First, args.After)
+}
+
+func (r *queryResolver) RoutingRevisions(ctx context.Context, args *struct {
+	ID    graphql.ID
+	First *int32
+	After *graphql.ID
+}) ([]*revision.Resolver, error) {
+	return revision.List(ctx, db.RevisionKindRouting, args.ID, args.First, args.After)
+}
+
+func (r *queryResolver) RemovedRevisions(ctx context.Context, args *struct {
+	Kind  string
+	First *int32
+	After *graphql.ID
+}) ([]*revision.Resolver, error) {
+	return revision.ListRemoved(ctx, strings.ToLower(args.Kind), args.First, args.After)
+}
+
+func (r *queryResolver) RevisionDiff(ctx context.Context, args *struct {
+	From graphql.ID
+	To   graphql.ID
+}) (string, error) {
+	return revision.Diff(ctx, args.From, args.To)
+}
+
+func (r *queryResolver) RunningDiff(ctx context.Context) ([]*config.SectionDiffResolver, error) {
+	return config.RunningDiff(db.DB(ctx))
+}
+
+func (r *queryResolver) Plan(ctx context.Context) (*config.PlanResolver, error) {
+	a, err := config.Plan(db.DB(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &config.PlanResolver{Assembly: a}, nil
+}
+
+func (r *queryResolver) RunProbes(ctx context.Context) (*probe.SettingsResolver, error) {
+	d := db.DB(ctx)
+	var probes []db.RunProbe
+	if err := d.Model(&db.RunProbe{}).Order("id").Find(&probes).Error; err != nil {
+		return nil, err
+	}
+	rollbackOnFailure, err := probe.RollbackOnFailure(d)
+	if err != nil {
+		return nil, err
+	}
+	rs := make([]*probe.Resolver, len(probes))
+	for i := range probes {
+		rs[i] = &probe.Resolver{RunProbe: &probes[i]}
+	}
+	return &probe.SettingsResolver{
+		Probes:            rs,
+		RollbackOnFailure: rollbackOnFailure,
+	}, nil
+}
+
+func (r *queryResolver) Logs(args *struct {
+	Since *graphql.Time
+	Level *string
+	Limit *int32
+	Text  *string
+}) ([]*logs.Resolver, error) {
+	filter, err := logFilter(args.Level, args.Text)
+	if err != nil {
+		return nil, err
+	}
+	limit := logs.DefaultLimit
+	if args.Limit != nil {
+		if *args.Limit <= 0 {
+			return nil, fmt.Errorf("limit should be positive")
+		}
+		limit = int(*args.Limit)
+	}
+	var since *time.Time
+	if args.Since != nil {
+		since = &args.Since.Time
+	}
+	entries := logs.Query(since, filter, limit)
+	rs := make([]*logs.Resolver, len(entries))
+	for i, e := range entries {
+		rs[i] = &logs.Resolver{Entry: e}
+	}
+	return rs, nil
+}
+
+// logFilter builds the filter of log entries from optional arguments.
+func logFilter(level *string, text *string) (*logs.Filter, error) {
+	var filter logs.Filter
+	var strLevel string
+	if level != nil {
+		strLevel = *level
+	}
+	var err error
+	if filter.MinLevel, err = logs.ParseLevel(strLevel); err != nil {
+		return nil, err
+	}
+	if text != nil {
+		filter.Text = *text
+	}
+	return &filter, nil
+}
+
+func (r *queryResolver) RenderedConfig(ctx context.Context, args *struct {
+	RedactLinks *bool
+}) (string, error) {
+	a, err := config.Assemble(db.DB(ctx))
+	if err != nil {
+		return "", err
+	}
+	return config.Render(a, args.RedactLinks != nil && *args.RedactLinks)
+}
+
 func (r *queryResolver) ConfigFlatDesc() []*dae.FlatDesc {
 	return dae.ExportFlatDesc()
 }
@@ -319,6 +606,15 @@ func (r *queryResolver) Group(args *struct{ Name string }) (rs *group.Resolver,
 	}
 	return &group.Resolver{Group: &m}, nil
 }
+func (r *queryResolver) PolicyDescriptions() []*group.PolicyDescription {
+	return group.PolicyDescriptions
+}
+func (r *queryResolver) PreviewGroupMembers(args *struct {
+	ID      graphql.ID
+	Filters *[]group.FilterInput
+}) ([]*node.Resolver, error) {
+	return group.PreviewMembers(context.TODO(), args.ID, args.Filters)
+}
 func (r *queryResolver) Groups(args *struct{ ID *graphql.ID }) (rs []*group.Resolver, err error) {
 	q := db.DB(context.TODO()).
 		Model(&db.Group{})
@@ -349,6 +645,8 @@ func (r *queryResolver) Nodes(args *struct {
 	SubscriptionID *graphql.ID
 	First          *int32
 	After          *graphql.ID
+	OrderBy        *string
+	Desc           *bool
 }) (rs *node.ConnectionResolver, err error) {
-	return node.NewConnectionResolver(args.ID, args.SubscriptionID, args.First, args.After)
+	return node.NewConnectionResolver(args.ID, args.SubscriptionID, args.First, args.After, args.OrderBy, args.Desc)
 }
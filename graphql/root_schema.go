@@ -10,7 +10,26 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/audit"
 	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/directives"
+)
+
+// schemaDefinition and readOnlySchemaDefinition declare root operation types. The read-only one never has the
+// mutation root, so that it cannot be exposed by accident.
+const (
+	schemaDefinition = `
+schema {
+	query: Query
+	mutation: Mutation
+	subscription: Events
+}`
+	readOnlySchemaDefinition = `
+schema {
+	query: Query
+	subscription: Events
+}`
 )
 
 var rootSchema = `
@@ -19,51 +38,142 @@ scalar Time
 
 directive @hasRole(role: Role!) on FIELD_DEFINITION
 
-schema {
-	query: Query
-	mutation: Mutation
-}
 type Query {
 	healthCheck: Int!
-	token(username: String!, password: String!): String!
+	# token logs in. otp is a TOTP code or a recovery code, which is required if the user enabled two-factor authentication.
+	token(username: String!, password: String!, otp: String): String!
 	numberUsers: Int!
+	# oidcEnabled indicates whether single sign-on is available.
+	oidcEnabled: Boolean!
+	# oidcAuthorizeUrl starts single sign-on and returns the URL of the OpenID provider to visit. The provider redirects back to redirectUri with "code" and "state" in the query.
+	oidcAuthorizeUrl(redirectUri: String!): String!
+	# oidcToken finishes single sign-on with "code" and "state" given by the OpenID provider. Return the same token as the token query.
+	# Users with two-factor authentication enabled should also give otp, a TOTP code or a recovery code. If it is missing or incorrect, oidcToken can be retried with the same code and state.
+	oidcToken(code: String!, state: String!, otp: String): String!
 	# jsonStorage get given paths from user related json storage. Empty paths is to get all. Refer to https://github.com/tidwall/gjson
-	jsonStorage(paths: [String!]): [String!]! @hasRole(role: ADMIN)
-    user: User! @hasRole(role: ADMIN)
-	configFlatDesc: [ConfigFlatDesc!]! @hasRole(role: ADMIN)
-	configs(id: ID, selected: Boolean): [Config!]! @hasRole(role: ADMIN)
-	dnss(id: ID, selected: Boolean): [Dns!]! @hasRole(role: ADMIN)
-	routings(id: ID, selected: Boolean): [Routing!]! @hasRole(role: ADMIN)
-	parsedRouting(raw: String!): DaeRouting! @hasRole(role: ADMIN)
-	parsedDns(raw: String!): DaeDns! @hasRole(role: ADMIN)
-	subscriptions(id: ID): [Subscription!]! @hasRole(role: ADMIN)
-	groups(id: ID): [Group!]! @hasRole(role: ADMIN)
-	group(name: String!): Group! @hasRole(role: ADMIN)
-	nodes(id: ID, subscriptionId: ID, first: Int, after: ID): NodesConnection! @hasRole(role: ADMIN)
-	general: General! @hasRole(role: ADMIN)
+	jsonStorage(paths: [String!]): [String!]! @hasRole(role: VIEWER)
+    user: User! @hasRole(role: VIEWER)
+	configFlatDesc: [ConfigFlatDesc!]! @hasRole(role: VIEWER)
+	configs(id: ID, selected: Boolean): [Config!]! @hasRole(role: VIEWER)
+	dnss(id: ID, selected: Boolean): [Dns!]! @hasRole(role: VIEWER)
+	routings(id: ID, selected: Boolean): [Routing!]! @hasRole(role: VIEWER)
+	# configRevisions lists revisions of the config with given id from the newest to the oldest.
+	configRevisions(id: ID!, first: Int, after: ID): [Revision!]! @hasRole(role: VIEWER)
+	# dnsRevisions lists revisions of the dns config with given id from the newest to the oldest.
+	dnsRevisions(id: ID!, first: Int, after: ID): [Revision!]! @hasRole(role: VIEWER)
+	# routingRevisions lists revisions of the routing config with given id from the newest to the oldest.
+	routingRevisions(id: ID!, first: Int, after: ID): [Revision!]! @hasRole(role: VIEWER)
+	# removedRevisions lists revisions of removed configs, dns or routings of the kind from the newest to the oldest.
+	# Restore one to create the removed target again.
+	removedRevisions(kind: RevisionKind!, first: Int, after: ID): [Revision!]! @hasRole(role: VIEWER)
+	# revisionDiff returns the unified diff from one revision to another of the same kind.
+	revisionDiff(from: ID!, to: ID!): String! @hasRole(role: VIEWER)
+	# runningDiff returns per-section unified diffs between the config loaded by the last run and the one the next run would load.
+	# An empty list means nothing would change. Runs before this was recorded are compared as empty.
+	runningDiff: [SectionDiff!]! @hasRole(role: VIEWER)
+	# renderedConfig returns the standalone dae config that a run would load from the selected config, dns and routing.
+	renderedConfig(redactLinks: Boolean): String! @hasRole(role: VIEWER)
+	# plan checks what a run would load from the selected config, dns and routing without loading it.
+	plan: Plan! @hasRole(role: VIEWER)
+	# runProbes are checked through the control plane after every run.
+	runProbes: RunProbeSettings! @hasRole(role: VIEWER)
+	# logs returns at most limit (default 100) latest log entries kept in memory after since, at level or more severe
+	# and containing text, from the oldest to the newest.
+	logs(since: Time, level: LogLevel, limit: Int, text: String): [LogEntry!]! @hasRole(role: ADMIN)
+	parsedRouting(raw: String!): DaeRouting! @hasRole(role: VIEWER)
+	parsedDns(raw: String!): DaeDns! @hasRole(role: VIEWER)
+	subscriptions(id: ID): [Subscription!]! @hasRole(role: VIEWER)
+	groups(id: ID): [Group!]! @hasRole(role: VIEWER)
+	group(name: String!): Group! @hasRole(role: VIEWER)
+	# policyDescriptions are policies of groups and their params.
+	policyDescriptions: [PolicyDescription!]! @hasRole(role: VIEWER)
+	# previewGroupMembers resolves nodes of the group as a run would do now. If filters are given, they are used
+	# instead of the group's.
+	previewGroupMembers(id: ID!, filters: [GroupFilterInput!]): [Node!]! @hasRole(role: VIEWER)
+	# nodes are ordered by id unless orderBy is given.
+	nodes(id: ID, subscriptionId: ID, first: Int, after: ID, orderBy: NodeOrderBy, desc: Boolean): NodesConnection! @hasRole(role: VIEWER)
+	general: General! @hasRole(role: VIEWER)
+	users: [User!]! @hasRole(role: ADMIN)
+	# apiTokens lists api tokens of current user.
+	apiTokens: [ApiToken!]! @hasRole(role: VIEWER)
+	# sessions lists unexpired login sessions of current user.
+	sessions: [Session!]! @hasRole(role: VIEWER)
+	# loginAttempts lists recent login attempts, newest first. first defaults to 50.
+	loginAttempts(username: String, success: Boolean, first: Int): [LoginAttempt!]! @hasRole(role: ADMIN)
+	# loginLockouts lists client IPs and usernames that are temporarily not allowed to log in.
+	loginLockouts: [LoginLockout!]! @hasRole(role: ADMIN)
+	# oidcConfig is null if single sign-on is not configured.
+	oidcConfig: OidcConfig @hasRole(role: ADMIN)
+	# auditLog lists audit logs of mutations from the newest to the oldest.
+	auditLog(first: Int, after: ID, filter: AuditLogFilter): AuditLogConnection! @hasRole(role: ADMIN)
+	# auditLogRetention is how long audit logs are kept. Zero means forever.
+	auditLogRetention: Duration! @hasRole(role: ADMIN)
+}
+# Events is the subscription root, served over WebSocket on the same endpoint with the graphql-transport-ws protocol of
+# graphql-ws. The token can be given in the "Authorization" field of the connection_init payload. Fields require the
+# VIEWER role unless noted.
+type Events {
+	# reloadEvents notify when dae starts or finishes loading a config, including rollbacks.
+	reloadEvents: ReloadEvent!
+	# subscriptionUpdateEvents notify results of updating subscriptions, scheduled or requested.
+	subscriptionUpdateEvents: SubscriptionUpdateEvent!
+	# runningStateEvents notify when general.dae.running changes.
+	runningStateEvents: RunningStateEvent!
+	# logs streams new log entries at level or more severe whose message or fields contain text. It requires the ADMIN
+	# role. Logs are also streamed as server-sent events on /logs/stream with the same query parameters.
+	logs(level: LogLevel, text: String): LogEntry!
 }
 type Mutation {
-	# createUser creates a user if there is no user.
+	# createUser creates the first user as an admin if there is no user. Use addUser to create more users.
 	createUser(username: String!, password: String!): String!
+	# addUser creates a user with given role.
+	addUser(username: String!, password: String!, role: Role!): User! @hasRole(role: ADMIN)
+	# removeUser removes a user with given user ID. The last admin cannot be removed.
+	removeUser(id: ID!): Int! @hasRole(role: ADMIN)
+	# setUserRole changes the role of a user with given user ID. The last admin cannot be demoted.
+	setUserRole(id: ID!, role: Role!): Int! @hasRole(role: ADMIN)
+	# createApiToken creates a long-lived api token for current user and returns it. The token cannot be retrieved again. Null expiresAt means never expire.
+	createApiToken(name: String!, scope: ApiTokenScope!, expiresAt: Time): String! @hasRole(role: VIEWER)
+	# revokeApiToken revokes an api token of current user.
+	revokeApiToken(id: ID!): Int! @hasRole(role: VIEWER)
+	# revokeSession logs out a session of current user.
+	revokeSession(id: ID!): Int! @hasRole(role: VIEWER)
+	# unlockLogin lifts the login lockout of a client IP or a username.
+	unlockLogin(kind: LoginLockoutKind!, key: String!): Int! @hasRole(role: ADMIN)
+	# setOidcConfig configures single sign-on through an OpenID provider. Null config disables it. Local users can always log in with passwords.
+	setOidcConfig(config: OidcConfigInput): Int! @hasRole(role: ADMIN)
+	# setAuditLogRetention sets how long audit logs are kept. Zero means forever.
+	setAuditLogRetention(retention: Duration!): Int! @hasRole(role: ADMIN)
 	# createConfig creates a global config. Null arguments will be converted to default value.
 	createConfig(name: String, global: globalInput): Config! @hasRole(role: ADMIN)
 	# createConfig creates a dns config. Null arguments will be converted to default value.
 	createDns(name: String, dns: String): Dns! @hasRole(role: ADMIN)
 	# createConfig creates a routing config. Null arguments will be converted to default value.
 	createRouting(name: String, routing: String): Routing! @hasRole(role: ADMIN)
+	# importDaeConfig splits a complete dae config into a config, dns, routing, groups, nodes and subscriptions. Name defaults to "imported".
+	# rollbackError means abort the whole import if any node or subscription fails to import.
+	importDaeConfig(raw: String!, name: String, rollbackError: Boolean): DaeConfigImportResult! @hasRole(role: ADMIN)
 
 	# setJsonStorage set given paths to values in user related json storage. Refer to https://github.com/tidwall/sjson
-	setJsonStorage(paths: [String!]!, values: [String!]!): Int! @hasRole(role: ADMIN)
+	setJsonStorage(paths: [String!]!, values: [String!]!): Int! @hasRole(role: VIEWER)
 	# removeJsonStorage remove given paths from user related json storage. Empty paths is to clear json storage. Refer to https://github.com/tidwall/sjson
-	removeJsonStorage(paths: [String!]): Int! @hasRole(role: ADMIN)
+	removeJsonStorage(paths: [String!]): Int! @hasRole(role: VIEWER)
 	# updateAvatar update avatar for current user. Remove avatar if avatar is null. Blob base64 encoded image is recommended.
-	updateAvatar(avatar: String): Int! @hasRole(role: ADMIN)
+	updateAvatar(avatar: String): Int! @hasRole(role: VIEWER)
 	# updateName update name for current user. Remove name if name is null.
-	updateName(name: String): Int! @hasRole(role: ADMIN)
+	updateName(name: String): Int! @hasRole(role: VIEWER)
 	# updateUsername update username for current user.
-	updateUsername(username: String!): Int! @hasRole(role: ADMIN)
+	updateUsername(username: String!): Int! @hasRole(role: VIEWER)
 	# updatePassword update password for current user. currentPassword is needed to authenticate. Return new token.
-	updatePassword(currentPassword: String!, newPassword: String!): String! @hasRole(role: ADMIN)
+	updatePassword(currentPassword: String!, newPassword: String!): String! @hasRole(role: VIEWER)
+	# enrollTotp generates a pending TOTP secret for current user. Call enableTotp with a valid code to take effect.
+	enrollTotp: TotpEnrollment! @hasRole(role: VIEWER)
+	# enableTotp enables two-factor authentication with a code from the enrolled secret. Return one-time recovery codes.
+	enableTotp(code: String!): [String!]! @hasRole(role: VIEWER)
+	# disableTotp disables two-factor authentication. code can be a TOTP code or a recovery code.
+	disableTotp(password: String!, code: String!): Int! @hasRole(role: VIEWER)
+	# regenerateRecoveryCodes invalidates existing recovery codes and returns new ones.
+	regenerateRecoveryCodes(code: String!): [String!]! @hasRole(role: VIEWER)
 
 	# updateConfig allows to partially update global config with given id.
 	updateConfig(id: ID!, global: globalInput!): Config! @hasRole(role: ADMIN)
@@ -79,6 +189,12 @@ type Mutation {
 	# renameRouting is to give the routing config a new name.
 	renameRouting(id: ID!, name: String!): Int! @hasRole(role: ADMIN)
 
+	# restoreRevision sets the config, dns or routing to the content of given revision, which is recorded as a new revision.
+	# If the target was removed, a new one is created with the content and takes over its revisions, so restoring again updates it.
+	restoreRevision(id: ID!): Revision! @hasRole(role: ADMIN)
+	# purgeRevisions removes given revisions of removed configs, dns or routings for good.
+	purgeRevisions(ids: [ID!]!): Int! @hasRole(role: ADMIN)
+
 	# removeConfig is to remove a config with given config ID.
 	removeConfig(id: ID!): Int! @hasRole(role: ADMIN)
 	# removeDns is to remove a dns config with given dns ID.
@@ -87,14 +203,24 @@ type Mutation {
 	removeRouting(id: ID!): Int! @hasRole(role: ADMIN)
 
 	# selectConfig is to select a config as the current config.
-	selectConfig(id: ID!): Int! @hasRole(role: ADMIN)
+	selectConfig(id: ID!): Int! @hasRole(role: OPERATOR)
 	# selectConfig is to select a dns config as the current dns.
-	selectDns(id: ID!): Int! @hasRole(role: ADMIN)
+	selectDns(id: ID!): Int! @hasRole(role: OPERATOR)
 	# selectConfig is to select a routing config as the current routing.
-	selectRouting(id: ID!): Int! @hasRole(role: ADMIN)
+	selectRouting(id: ID!): Int! @hasRole(role: OPERATOR)
 
 	# run proxy with selected config+dns+routing. Dry-run can be used to stop the proxy.
-	run(dry: Boolean!): Int! @hasRole(role: ADMIN)
+	# If confirmTimeout is given, the previous running state is restored unless confirmRun is called within it.
+	# Probes set by setRunProbes are checked after loading. If any fails and rollbackOnFailure is set, the previous config
+	# is loaded back and an error is returned. Results are available at general.dae.probes.
+	run(dry: Boolean!, confirmTimeout: Duration): Int! @hasRole(role: OPERATOR)
+	# confirmRun keeps the last run started with confirmTimeout.
+	confirmRun: Int! @hasRole(role: OPERATOR)
+	# setRunProbes replaces probes checked after every run.
+	setRunProbes(probes: [RunProbeInput!]!, rollbackOnFailure: Boolean!): Int! @hasRole(role: ADMIN)
+	# setLogLevel changes the log level immediately without reloading. It reverts to the level of the running config
+	# after duration if given, or otherwise lasts until the next setLogLevel or restart.
+	setLogLevel(level: LogLevel!, duration: Duration): Int! @hasRole(role: ADMIN)
 
 	# importNodes is to import nodes with no subscription ID. rollbackError means abort the import on error.
 	importNodes(rollbackError: Boolean!, args: [ImportArgument!]!): [NodeImportResult!]! @hasRole(role: ADMIN)
@@ -102,6 +228,11 @@ type Mutation {
 	# updateNode is to update a node with no subscription ID.
 	updateNode(id: ID!, newLink: String!): Node! @hasRole(role: ADMIN)
 
+	# testNodes checks connectivity through nodes concurrently and saves their latency. url defaults to
+	# "http://cp.cloudflare.com" and can be http, https or tcp://host:port, which only connects through the node. Note that
+	# some protocols connect lazily, so tcp may not reach the node. A check of http or https succeeds only with a 2xx status.
+	# timeout of each node defaults to 5s and is at most 1m. Nodes that are not found are skipped.
+	testNodes(ids: [ID!]!, url: String, timeout: Duration): [NodeTestResult!]! @hasRole(role: OPERATOR)
 	# removeNodes is to remove nodes that have no subscription ID.
 	removeNodes(ids: [ID!]!): Int! @hasRole(role: ADMIN)
 
@@ -118,7 +249,7 @@ type Mutation {
 	tagSubscription(id: ID!, tag: String!): Int! @hasRole(role: ADMIN)
 
 	# updateSubscription is to re-fetch subscription and resolve subscription into nodes. Old nodes that independently belong to any groups will not be removed.
-	updateSubscription(id: ID!): Subscription! @hasRole(role: ADMIN)
+	updateSubscription(id: ID!): Subscription! @hasRole(role: OPERATOR)
 
 	# updateSubscriptionLink is to update the subscription link without re-fetching nodes.
 	updateSubscriptionLink(id: ID!, link: String!): Subscription! @hasRole(role: ADMIN)
@@ -144,14 +275,28 @@ type Mutation {
 	# groupDelNodes is to remove nodes from the group.
 	groupDelNodes(id: ID!, nodeIDs: [ID!]!): Int! @hasRole(role: ADMIN)
 
+	# groupSetFilters is to replace filters of the group. Matching nodes are resolved at every run.
+	groupSetFilters(id: ID!, filters: [GroupFilterInput!]!): Int! @hasRole(role: ADMIN)
+
+	# pinGroupNode is to make the group always choose the node in it. It takes effect immediately if dae is running and
+	# is kept across runs until unpinGroup.
+	pinGroupNode(groupId: ID!, nodeId: ID!): Int! @hasRole(role: OPERATOR)
+
+	# unpinGroup is to restore the policy of the group. It takes effect immediately if dae is running, unless dae was
+	# started with the group pinned; the running config is then reported modified until the next run.
+	unpinGroup(groupId: ID!): Int! @hasRole(role: OPERATOR)
+
 	# renameGroup is to rename a group.
 	renameGroup(id: ID!, name: String!): Int! @hasRole(role: ADMIN)
 
 	# removeGroup is to remove a group.
 	removeGroup(id: ID!): Int! @hasRole(role: ADMIN)
 }
+# Role is ordered by privilege: ADMIN > OPERATOR > VIEWER.
 enum Role {
 	ADMIN
+	OPERATOR
+	VIEWER
 }
 input ImportArgument {
 	link: String!
@@ -191,16 +336,31 @@ func (h *hasRoleDirective) ImplementsDirective() string {
 }
 
 func (h *hasRoleDirective) Validate(ctx context.Context, _ interface{}) error {
-	role := ctx.Value("role")
-	if role == nil {
+	return checkRole(ctx, strings.ToLower(h.Role))
+}
+
+// checkRole checks if the user in ctx has the required role, which is one of db.RoleAdmin, db.RoleOperator and
+// db.RoleViewer.
+func checkRole(ctx context.Context, required string) error {
+	role, ok := ctx.Value("role").(string)
+	if !ok {
 		return fmt.Errorf("access denied")
 	}
-	if !strings.EqualFold(role.(string), h.Role) {
-		return fmt.Errorf("access denied, %q role required", h.Role)
+	if !db.RoleSatisfies(role, required) {
+		return fmt.Errorf("access denied, %q role required", strings.ToUpper(required))
 	}
 	return nil
 }
 
+// Resolve lets the audit log capture results of mutations.
+func (h *hasRoleDirective) Resolve(ctx context.Context, args interface{}, next directives.Resolver) (output interface{}, err error) {
+	output, err = next.Resolve(ctx, args)
+	if err == nil {
+		audit.Capture(ctx, output)
+	}
+	return output, err
+}
+
 type resolver struct{}
 
 func (*resolver) Query() *queryResolver {
@@ -211,8 +371,17 @@ func (*resolver) Mutation() *MutationResolver {
 	return &MutationResolver{}
 }
 
+func (*resolver) Subscription() *subscriptionResolver {
+	return &subscriptionResolver{}
+}
+
 func SchemaString() (string, error) {
+	return schemaString(schemaDefinition)
+}
+
+func schemaString(definition string) (string, error) {
 	var sb strings.Builder
+	sb.WriteString(definition)
 	sb.WriteString(rootSchema)
 	for _, c := range schemaChains {
 		s, err := c()
@@ -234,5 +403,25 @@ func Schema() (*graphql.Schema, error) {
 		&resolver{},
 		graphql.UseFieldResolvers(),
 		graphql.Directives(&hasRoleDirective{}),
+		graphql.Tracer(metricsTracer{}),
 	), nil
 }
+
+// ReadOnlySchema is the same as Schema but has no mutation root, which is served to read-only api tokens.
+func ReadOnlySchema() (*graphql.Schema, error) {
+	schema, err := schemaString(readOnlySchemaDefinition)
+	if err != nil {
+		return nil, err
+	}
+	s := graphql.MustParseSchema(
+		schema,
+		&resolver{},
+		graphql.UseFieldResolvers(),
+		graphql.Directives(&hasRoleDirective{}),
+		graphql.Tracer(metricsTracer{}),
+	)
+	if _, ok := s.ASTSchema().RootOperationTypes["mutation"]; ok {
+		panic("read-only schema has a mutation root")
+	}
+	return s, nil
+}
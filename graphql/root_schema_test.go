@@ -0,0 +1,31 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadOnlySchema(t *testing.T) {
+	full, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := full.ASTSchema().RootOperationTypes["mutation"]; !ok {
+		t.Fatal("expected the full schema to have a mutation root")
+	}
+	readOnly, err := ReadOnlySchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readOnly.ASTSchema().RootOperationTypes["mutation"]; ok {
+		t.Fatal("expected the read-only schema to have no mutation root")
+	}
+	if resp := readOnly.Exec(context.Background(), `mutation { run(dry: true) }`, "", nil); len(resp.Errors) == 0 {
+		t.Fatalf("expected mutations to be rejected, got %s", resp.Data)
+	}
+}
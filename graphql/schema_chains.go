@@ -7,13 +7,23 @@ package graphql
 
 import (
 	"github.com/daeuniverse/dae-wing/graphql/service"
+	"github.com/daeuniverse/dae-wing/graphql/service/apitoken"
+	"github.com/daeuniverse/dae-wing/graphql/service/audit"
 	"github.com/daeuniverse/dae-wing/graphql/service/config"
 	"github.com/daeuniverse/dae-wing/graphql/service/config/global"
 	"github.com/daeuniverse/dae-wing/graphql/service/dns"
+	"github.com/daeuniverse/dae-wing/graphql/service/event"
 	"github.com/daeuniverse/dae-wing/graphql/service/general"
 	"github.com/daeuniverse/dae-wing/graphql/service/group"
+	"github.com/daeuniverse/dae-wing/graphql/service/importer"
+	"github.com/daeuniverse/dae-wing/graphql/service/login"
+	"github.com/daeuniverse/dae-wing/graphql/service/logs"
 	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae-wing/graphql/service/oidc"
+	"github.com/daeuniverse/dae-wing/graphql/service/probe"
+	"github.com/daeuniverse/dae-wing/graphql/service/revision"
 	"github.com/daeuniverse/dae-wing/graphql/service/routing"
+	"github.com/daeuniverse/dae-wing/graphql/service/session"
 	"github.com/daeuniverse/dae-wing/graphql/service/subscription"
 	"github.com/daeuniverse/dae-wing/graphql/service/user"
 )
@@ -31,4 +41,14 @@ var schemaChains = []SchemaChain{
 	node.Schema,
 	subscription.Schema,
 	user.Schema,
+	apitoken.Schema,
+	session.Schema,
+	login.Schema,
+	oidc.Schema,
+	audit.Schema,
+	revision.Schema,
+	importer.Schema,
+	probe.Schema,
+	event.Schema,
+	logs.Schema,
 }
@@ -0,0 +1,110 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+	"gorm.io/gorm"
+)
+
+// TokenPrefix distinguishes API tokens from JWTs in the Authorization header.
+const TokenPrefix = "wing_"
+
+// lastUsedPrecision limits how often LastUsedAt is written back.
+const lastUsedPrecision = time.Minute
+
+var ErrInvalidToken = fmt.Errorf("invalid api token")
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func IsApiToken(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+func Create(ctx context.Context, u *db.User, name string, scope string, expiresAt *graphql.Time) (token string, err error) {
+	switch scope {
+	case db.ApiTokenScopeReadOnly, db.ApiTokenScopeFull:
+	default:
+		return "", fmt.Errorf("unknown scope: %v", scope)
+	}
+	m := db.ApiToken{
+		Name:      name,
+		Scope:     scope,
+		CreatedAt: time.Now(),
+		UserID:    u.ID,
+	}
+	if expiresAt != nil {
+		if !expiresAt.After(m.CreatedAt) {
+			return "", fmt.Errorf("expiresAt should be in the future")
+		}
+		m.ExpiresAt = &expiresAt.Time
+	}
+	var b [32]byte
+	if _, err = io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	token = TokenPrefix + hex.EncodeToString(b[:])
+	m.TokenHash = hashToken(token)
+	if err = db.DB(ctx).Create(&m).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func Revoke(ctx context.Context, u *db.User, _id graphql.ID) (n int32, err error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return 0, err
+	}
+	q := db.DB(ctx).
+		Where("id = ?", id).
+		Where("user_id = ?", u.ID).
+		Delete(&db.ApiToken{})
+	if q.Error != nil {
+		return 0, q.Error
+	}
+	return int32(q.RowsAffected), nil
+}
+
+// Authenticate finds the api token and its owner. Expired tokens are rejected.
+func Authenticate(ctx context.Context, token string) (*db.ApiToken, error) {
+	var m db.ApiToken
+	if err := db.DB(ctx).
+		Preload("User").
+		Where("token_hash = ?", hashToken(token)).
+		First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if m.ExpiresAt != nil && now.After(*m.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if m.LastUsedAt == nil || now.Sub(*m.LastUsedAt) > lastUsedPrecision {
+		if err := db.DB(ctx).Model(&db.ApiToken{ID: m.ID}).Update("last_used_at", now).Error; err != nil {
+			return nil, err
+		}
+		m.LastUsedAt = &now
+	}
+	return &m, nil
+}
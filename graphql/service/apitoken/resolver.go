@@ -0,0 +1,48 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package apitoken
+
+import (
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type Resolver struct {
+	*db.ApiToken
+}
+
+func (r *Resolver) ID() graphql.ID {
+	return common.EncodeCursor(r.ApiToken.ID)
+}
+
+func (r *Resolver) Name() string {
+	return r.ApiToken.Name
+}
+
+func (r *Resolver) Scope() string {
+	return strings.ToUpper(r.ApiToken.Scope)
+}
+
+func (r *Resolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.ApiToken.CreatedAt}
+}
+
+func (r *Resolver) ExpiresAt() *graphql.Time {
+	if r.ApiToken.ExpiresAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.ApiToken.ExpiresAt}
+}
+
+func (r *Resolver) LastUsedAt() *graphql.Time {
+	if r.ApiToken.LastUsedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.ApiToken.LastUsedAt}
+}
@@ -0,0 +1,23 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package apitoken
+
+func Schema() (string, error) {
+	return `
+type ApiToken {
+	id: ID!
+	name: String!
+	scope: ApiTokenScope!
+	createdAt: Time!
+	expiresAt: Time
+	lastUsedAt: Time
+}
+enum ApiTokenScope {
+	READ_ONLY
+	FULL
+}
+`, nil
+}
@@ -0,0 +1,131 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package audit
+
+import (
+	"context"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service"
+	"github.com/graph-gophers/graphql-go"
+	"gorm.io/gorm"
+)
+
+type Filter struct {
+	UserId   *graphql.ID
+	Username *string
+	Mutation *string
+	Failed   *bool
+	Since    *graphql.Time
+	Until    *graphql.Time
+}
+
+type ConnectionResolver struct {
+	baseQuery func() *gorm.DB
+
+	models []db.AuditLog
+}
+
+// NewConnectionResolver lists audit logs from the newest to the oldest.
+func NewConnectionResolver(first *int32, _after *graphql.ID, filter *Filter) (r *ConnectionResolver, err error) {
+	var userId uint
+	if filter != nil && filter.UserId != nil {
+		userId, err = common.DecodeCursor(*filter.UserId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	baseQuery := func() *gorm.DB {
+		q := db.DB(context.TODO()).Model(&db.AuditLog{})
+		if filter == nil {
+			return q
+		}
+		if filter.UserId != nil {
+			q = q.Where("user_id = ?", userId)
+		}
+		if filter.Username != nil {
+			q = q.Where("username = ?", *filter.Username)
+		}
+		if filter.Mutation != nil {
+			q = q.Where("mutation = ?", *filter.Mutation)
+		}
+		if filter.Failed != nil {
+			if *filter.Failed {
+				q = q.Where("error != ''")
+			} else {
+				q = q.Where("error = ''")
+			}
+		}
+		if filter.Since != nil {
+			q = q.Where("created_at >= ?", filter.Since.Time)
+		}
+		if filter.Until != nil {
+			q = q.Where("created_at < ?", filter.Until.Time)
+		}
+		return q
+	}
+
+	q := baseQuery()
+	if _after != nil {
+		after, err := common.DecodeCursor(*_after)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("id < ?", after)
+	}
+	if first != nil {
+		q = q.Limit(int(*first))
+	}
+	var models []db.AuditLog
+	if err = q.Order("id DESC").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	return &ConnectionResolver{
+		baseQuery: baseQuery,
+		models:    models,
+	}, nil
+}
+
+func (r *ConnectionResolver) TotalCount() (int32, error) {
+	var count int64
+	if err := r.baseQuery().Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int32(count), nil
+}
+
+func (r *ConnectionResolver) Edges() (rs []*Resolver, err error) {
+	for _, _m := range r.models {
+		m := _m
+		rs = append(rs, &Resolver{
+			AuditLog: &m,
+		})
+	}
+	return rs, nil
+}
+
+func (r *ConnectionResolver) PageInfo() (pr *service.PageInfoResolver, err error) {
+	if len(r.models) == 0 {
+		return &service.PageInfoResolver{
+			FStartCursor: nil,
+			FEndCursor:   nil,
+			FHasNextPage: false,
+		}, nil
+	}
+	start := common.EncodeCursor(r.models[0].ID)
+	end := common.EncodeCursor(r.models[len(r.models)-1].ID)
+	// Get the oldest ID.
+	var oldest db.AuditLog
+	if err := r.baseQuery().Select("id").Order("id").First(&oldest).Error; err != nil {
+		return nil, err
+	}
+	return &service.PageInfoResolver{
+		FStartCursor: &start,
+		FEndCursor:   &end,
+		FHasNextPage: r.models[len(r.models)-1].ID > oldest.ID,
+	}, nil
+}
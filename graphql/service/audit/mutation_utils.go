@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+// pruneInterval limits how often outdated audit logs are removed.
+const pruneInterval = time.Hour
+
+var (
+	muPrune   sync.Mutex
+	lastPrune time.Time
+)
+
+// Append appends an audit log and removes outdated ones by the way.
+func Append(ctx context.Context, m *db.AuditLog) error {
+	if err := db.DB(ctx).Create(m).Error; err != nil {
+		return err
+	}
+	muPrune.Lock()
+	defer muPrune.Unlock()
+	if time.Since(lastPrune) < pruneInterval {
+		return nil
+	}
+	lastPrune = time.Now()
+	return Prune(ctx)
+}
+
+// Prune removes audit logs exceeding the retention.
+func Prune(ctx context.Context) error {
+	retention, err := Retention(ctx)
+	if err != nil {
+		return err
+	}
+	if retention == 0 {
+		return nil
+	}
+	return db.DB(ctx).
+		Where("created_at < ?", time.Now().Add(-retention)).
+		Delete(&db.AuditLog{}).Error
+}
+
+func Retention(ctx context.Context) (time.Duration, error) {
+	var sys db.System
+	if err := db.DB(ctx).Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		return 0, err
+	}
+	return sys.AuditLogRetention, nil
+}
+
+func SetRetention(ctx context.Context, retention time.Duration) (err error) {
+	if retention < 0 {
+		return fmt.Errorf("retention should not be negative")
+	}
+	if retention != 0 && retention < time.Hour {
+		return fmt.Errorf("retention should be at least 1h")
+	}
+	var sys db.System
+	if err = db.DB(ctx).Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		return err
+	}
+	if err = db.DB(ctx).Model(&sys).Update("audit_log_retention", retention).Error; err != nil {
+		return err
+	}
+	return Prune(ctx)
+}
@@ -0,0 +1,63 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package audit
+
+import (
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type Resolver struct {
+	*db.AuditLog
+}
+
+func (r *Resolver) ID() graphql.ID {
+	return common.EncodeCursor(r.AuditLog.ID)
+}
+
+func (r *Resolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.AuditLog.CreatedAt}
+}
+
+func (r *Resolver) UserId() *graphql.ID {
+	if r.AuditLog.UserID == nil {
+		return nil
+	}
+	id := common.EncodeCursor(*r.AuditLog.UserID)
+	return &id
+}
+
+func (r *Resolver) Username() string {
+	return r.AuditLog.Username
+}
+
+func (r *Resolver) ClientIp() string {
+	return r.AuditLog.ClientIp
+}
+
+func (r *Resolver) Mutation() string {
+	return r.AuditLog.Mutation
+}
+
+func (r *Resolver) Args() *string {
+	return optional(r.AuditLog.Args)
+}
+
+func (r *Resolver) Result() *string {
+	return optional(r.AuditLog.Result)
+}
+
+func (r *Resolver) Error() *string {
+	return optional(r.AuditLog.Error)
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
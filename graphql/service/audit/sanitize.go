@@ -0,0 +1,133 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	redacted     = "[REDACTED]"
+	maxStringLen = 512
+	maxResultLen = 1024
+)
+
+var (
+	// sensitiveArg matches names of arguments that should never be recorded.
+	// Raw dae configs are included because they carry node and subscription links.
+	sensitiveArg = regexp.MustCompile(`(?i)password|secret|token|otp|^code$|^raw$`)
+	// linkArg matches names of arguments holding node or subscription links, which may carry credentials.
+	linkArg = regexp.MustCompile(`(?i)link`)
+	// secretResults are mutations returning credentials.
+	secretResults = map[string]struct{}{
+		"createUser":              {},
+		"updatePassword":          {},
+		"createApiToken":          {},
+		"enrollTotp":              {},
+		"enableTotp":              {},
+		"regenerateRecoveryCodes": {},
+	}
+)
+
+func sanitize(name string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			m[k] = sanitize(k, vv)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, vv := range v {
+			l[i] = sanitize(name, vv)
+		}
+		return l
+	case nil:
+		return nil
+	}
+	if sensitiveArg.MatchString(name) {
+		return redacted
+	}
+	if s, ok := v.(string); ok {
+		if linkArg.MatchString(name) {
+			return common.RedactLink(s)
+		}
+		return truncate(s, maxStringLen)
+	}
+	return v
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func sanitizeArgs(args map[string]interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(sanitize("", args))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+type ider interface {
+	ID() graphql.ID
+}
+
+// summarize keeps scalars and IDs of objects. Lists of other objects are represented by their lengths.
+func summarize(v interface{}) (interface{}, bool) {
+	if r, ok := v.(ider); ok {
+		return map[string]interface{}{"id": r.ID()}, true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return v, true
+	case reflect.Slice:
+		l := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			e, ok := summarize(rv.Index(i).Interface())
+			if !ok {
+				return map[string]interface{}{"count": rv.Len()}, true
+			}
+			l = append(l, e)
+		}
+		return l, true
+	default:
+		return nil, false
+	}
+}
+
+func summarizeResult(mutation string, result interface{}) string {
+	if result == nil {
+		return ""
+	}
+	if _, ok := secretResults[mutation]; ok {
+		return redacted
+	}
+	v, ok := summarize(result)
+	if !ok {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return truncate(string(b), maxResultLen)
+}
@@ -0,0 +1,122 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package audit_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/daeuniverse/dae-wing/graphql"
+	"github.com/daeuniverse/dae-wing/graphql/service/audit"
+	"github.com/graph-gophers/graphql-go/ast"
+)
+
+func namedType(t ast.Type) ast.Type {
+	for {
+		switch tt := t.(type) {
+		case *ast.NonNull:
+			t = tt.OfType
+		case *ast.List:
+			t = tt.OfType
+		default:
+			return t
+		}
+	}
+}
+
+// TestMutationArgs makes sure every argument of mutations, including fields of input objects, is either sanitized or
+// known to be safe to record.
+func TestMutationArgs(t *testing.T) {
+	schema, err := graphql.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := schema.ASTSchema()
+	names := map[string]string{}
+	seen := map[string]bool{}
+	var walk func(where string, args ast.ArgumentsDefinition)
+	walk = func(where string, args ast.ArgumentsDefinition) {
+		for _, arg := range args {
+			names[arg.Name.Name] = where + "." + arg.Name.Name
+			if in, ok := namedType(arg.Type).(*ast.InputObject); ok && !seen[in.Name] {
+				seen[in.Name] = true
+				walk(in.Name, in.Values)
+			}
+		}
+	}
+	mutation := s.Types["Mutation"].(*ast.ObjectTypeDefinition)
+	for _, f := range mutation.Fields {
+		walk(f.Name, f.Arguments)
+	}
+	const link = "vmess://secret-uuid@example.com:443"
+	var unknown []string
+	for name, where := range names {
+		if audit.Sanitize(name, link) != link {
+			continue
+		}
+		if _, ok := plainArgs[name]; !ok {
+			unknown = append(unknown, where)
+		}
+	}
+	sort.Strings(unknown)
+	for _, where := range unknown {
+		t.Errorf("argument %v is recorded as is; sanitize it or add it to plainArgs", where)
+	}
+}
+
+// plainArgs are names of mutation arguments that are safe to record as is.
+var plainArgs = map[string]struct{}{
+	// Input objects and lists, whose fields are sanitized by their own names.
+	"arg": {}, "args": {}, "config": {}, "filters": {}, "global": {}, "policyParams": {}, "probes": {}, "values": {},
+
+	"id": {}, "ids": {}, "groupId": {}, "nodeId": {}, "nodeIDs": {}, "subscriptionIDs": {},
+	"name": {}, "username": {}, "tag": {}, "avatar": {}, "paths": {}, "key": {}, "val": {}, "value": {},
+	"kind": {}, "exclude": {}, "policy": {}, "role": {}, "scope": {}, "scopes": {}, "expiresAt": {},
+	"issuer": {}, "clientId": {}, "roleClaim": {}, "roleMapping": {}, "defaultRole": {}, "usernameClaim": {},
+	"dns": {}, "routing": {}, "server": {}, "target": {}, "url": {}, "timeout": {}, "duration": {}, "level": {},
+	"dry": {}, "confirmTimeout": {}, "rollbackError": {}, "rollbackOnFailure": {}, "retention": {},
+	"cronEnable": {}, "cronExp": {},
+
+	// Fields of the global input.
+	"allowInsecure": {}, "autoConfigFirewallRule": {}, "autoConfigKernelParameter": {}, "bandwidthMaxRx": {},
+	"bandwidthMaxTx": {}, "checkInterval": {}, "checkTolerance": {}, "dialMode": {}, "disableWaitingNetwork": {},
+	"enableLocalTcpFastRedirect": {}, "fallbackResolver": {}, "lanInterface": {}, "logLevel": {}, "mptcp": {},
+	"pprofPort": {}, "sniffingTimeout": {}, "soMarkFromDae": {}, "tcpCheckHttpMethod": {}, "tcpCheckUrl": {},
+	"tlsImplementation": {}, "tproxyPort": {}, "tproxyPortProtect": {}, "udpCheckDns": {}, "utlsImitate": {},
+	"wanInterface": {},
+}
+
+func TestSanitize(t *testing.T) {
+	const link = "vmess://secret-uuid@example.com:443#name"
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "password", value: "p4ssword", want: "[REDACTED]"},
+		{name: "newPassword", value: "p4ssword", want: "[REDACTED]"},
+		{name: "clientSecret", value: "s", want: "[REDACTED]"},
+		{name: "otp", value: "123456", want: "[REDACTED]"},
+		{name: "code", value: "abc", want: "[REDACTED]"},
+		{name: "raw", value: "node { a: 'vmess://x' }", want: "[REDACTED]"},
+		{name: "link", value: link, want: "vmess://[REDACTED]"},
+		{name: "newLink", value: link, want: "vmess://[REDACTED]"},
+		{name: "links", value: []interface{}{link}, want: []interface{}{"vmess://[REDACTED]"}},
+		{name: "args", value: []interface{}{map[string]interface{}{"link": link, "tag": "t"}},
+			want: []interface{}{map[string]interface{}{"link": "vmess://[REDACTED]", "tag": "t"}}},
+		{name: "name", value: "plain", want: "plain"},
+		{name: "dry", value: true, want: true},
+		{name: "id", value: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := audit.Sanitize(tt.name, tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package audit
+
+func Schema() (string, error) {
+	return `
+type AuditLog {
+	id: ID!
+	createdAt: Time!
+	# userId is null if the mutation was called without authentication.
+	userId: ID
+	username: String!
+	clientIp: String!
+	mutation: String!
+	# args is sanitized arguments in json. Passwords, secrets and credentials in links are redacted.
+	args: String
+	# result is the result in json. Objects are represented by their IDs and credentials are redacted.
+	result: String
+	error: String
+}
+type AuditLogConnection {
+	totalCount: Int!
+	edges: [AuditLog!]!
+	pageInfo: PageInfo!
+}
+input AuditLogFilter {
+	userId: ID
+	username: String
+	mutation: String
+	# failed filters mutations that returned errors or not.
+	failed: Boolean
+	since: Time
+	until: Time
+}
+`, nil
+}
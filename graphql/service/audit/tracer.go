@@ -0,0 +1,83 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace/noop"
+	"github.com/graph-gophers/graphql-go/trace/tracer"
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey struct{}
+
+// state is carried by the context of a request. Root fields of a mutation are executed serially,
+// so one state per request is enough.
+type state struct {
+	current *entry
+}
+
+type entry struct {
+	captured bool
+	result   interface{}
+}
+
+// Tracer wraps the execution of every root field of Mutation and appends an audit log for it.
+type Tracer struct {
+	noop.Tracer
+}
+
+var _ tracer.Tracer = Tracer{}
+
+func (t Tracer) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, tracer.QueryFinishFunc) {
+	return context.WithValue(ctx, contextKey{}, &state{}), func([]*errors.QueryError) {}
+}
+
+func (t Tracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, tracer.FieldFinishFunc) {
+	s, ok := ctx.Value(contextKey{}).(*state)
+	if !ok || typeName != "Mutation" {
+		return ctx, func(*errors.QueryError) {}
+	}
+	e := &entry{}
+	s.current = e
+	createdAt := time.Now()
+	return ctx, func(err *errors.QueryError) {
+		s.current = nil
+		m := db.AuditLog{
+			CreatedAt: createdAt,
+			Mutation:  fieldName,
+			Args:      sanitizeArgs(args),
+		}
+		if u, ok := ctx.Value("user").(*db.User); ok {
+			m.UserID = &u.ID
+			m.Username = u.Username
+		}
+		m.ClientIp, _ = ctx.Value("clientIp").(string)
+		if err != nil {
+			m.Error = err.Message
+		} else {
+			m.Result = summarizeResult(fieldName, e.result)
+		}
+		if err := Append(context.Background(), &m); err != nil {
+			logrus.WithError(err).Warnln("Failed to append audit log")
+		}
+	}
+}
+
+// Capture keeps the output of the mutation being resolved. It is called by the field resolver interceptor.
+func Capture(ctx context.Context, output interface{}) {
+	s, ok := ctx.Value(contextKey{}).(*state)
+	if !ok || s.current == nil || s.current.captured {
+		return
+	}
+	s.current.captured = true
+	s.current.result = output
+}
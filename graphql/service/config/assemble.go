@@ -0,0 +1,413 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/dae"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/group"
+	daeCommon "github.com/daeuniverse/dae/common"
+	daeConfig "github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"gorm.io/gorm"
+)
+
+// Assembly is what Run loads: the selected global, dns and routing, and groups and nodes referenced by the routing.
+type Assembly struct {
+	Config    *daeConfig.Config
+	Global    *db.Config
+	Dns       *db.Dns
+	Routing   *db.Routing
+	Groups    []db.Group
+	Nodes     []*AssembledNode
+	Outbounds []string
+
+	// Errors prevent the assembly from being loaded. Config may be nil if there is any.
+	Errors   []*Issue
+	Warnings []*Issue
+}
+
+// AssembledNode is a node in the generated node section.
+type AssembledNode struct {
+	// Name is the unique name in the generated config.
+	Name   string
+	Node   *db.Node
+	Groups []string
+}
+
+const (
+	IssueConfigNotSelected       = "CONFIG_NOT_SELECTED"
+	IssueDnsNotSelected          = "DNS_NOT_SELECTED"
+	IssueRoutingNotSelected      = "ROUTING_NOT_SELECTED"
+	IssueBadConfig               = "BAD_CONFIG"
+	IssueGroupNotFound           = "GROUP_NOT_FOUND"
+	IssueEmptyGroup              = "EMPTY_GROUP"
+	IssueFixedGroupMultipleNodes = "FIXED_GROUP_MULTIPLE_NODES"
+	IssueUnusedGroup             = "UNUSED_GROUP"
+	IssueEmptySubscription       = "EMPTY_SUBSCRIPTION"
+	IssueNodeRenamed             = "NODE_RENAMED"
+	IssuePinnedNodeNotInGroup    = "PINNED_NODE_NOT_IN_GROUP"
+	IssueBadPolicy               = "BAD_POLICY"
+)
+
+// Issue is a problem found in assembling.
+type Issue struct {
+	Code    string
+	Message string
+	// Group is the name of the group concerned, if any.
+	Group *string
+}
+
+func (a *Assembly) error(code string, group *string, format string, args ...interface{}) {
+	a.Errors = append(a.Errors, &Issue{Code: code, Message: fmt.Sprintf(format, args...), Group: group})
+}
+
+func (a *Assembly) warn(code string, group *string, format string, args ...interface{}) {
+	a.Warnings = append(a.Warnings, &Issue{Code: code, Message: fmt.Sprintf(format, args...), Group: group})
+}
+
+// Err joins errors of the assembly.
+func (a *Assembly) Err() error {
+	if len(a.Errors) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(a.Errors))
+	for i, e := range a.Errors {
+		msgs[i] = e.Message
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Assemble assembles the dae config from the selected global, dns and routing.
+func Assemble(d *gorm.DB) (*Assembly, error) {
+	a, err := Plan(d)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Plan is like Assemble but collects all problems in Errors and Warnings instead of failing on the first one.
+// A non-nil error means the database cannot be read.
+func Plan(d *gorm.DB) (*Assembly, error) {
+	a := &Assembly{}
+	//// Run selected global+dns+routing.
+	/// Get them from database and parse them to daeConfig.
+	var mConfig db.Config
+	var mDns db.Dns
+	var mRouting db.Routing
+	q := d.Model(&db.Config{}).Where("selected = ?", true).First(&mConfig)
+	if (q.Error == nil && q.RowsAffected == 0) || errors.Is(q.Error, gorm.ErrRecordNotFound) {
+		a.error(IssueConfigNotSelected, nil, "please select a config")
+	} else if q.Error != nil {
+		return nil, q.Error
+	}
+	q = d.Model(&db.Dns{}).Where("selected = ?", true).First(&mDns)
+	if (q.Error == nil && q.RowsAffected == 0) || errors.Is(q.Error, gorm.ErrRecordNotFound) {
+		a.error(IssueDnsNotSelected, nil, "please select a dns")
+	} else if q.Error != nil {
+		return nil, q.Error
+	}
+	q = d.Model(&db.Routing{}).Where("selected = ?", true).First(&mRouting)
+	if (q.Error == nil && q.RowsAffected == 0) || errors.Is(q.Error, gorm.ErrRecordNotFound) {
+		a.error(IssueRoutingNotSelected, nil, "please select a routing")
+	} else if q.Error != nil {
+		return nil, q.Error
+	}
+	if len(a.Errors) > 0 {
+		return a, nil
+	}
+	a.Global, a.Dns, a.Routing = &mConfig, &mDns, &mRouting
+	c, err := dae.ParseConfig(&mConfig.Global, &mDns.Dns, &mRouting.Routing)
+	if err != nil {
+		a.error(IssueBadConfig, nil, "%v", err)
+		return a, nil
+	}
+	/// Fill in necessary groups and nodes.
+	// Find groups needed by routing.
+	outbounds := dae.NecessaryOutbounds(&c.Routing)
+	a.Outbounds = outbounds
+	// Keep the order stable so that the rendered config can be compared. Groups must not be reordered afterwards
+	// because nodes refer to them by pointers.
+	var groups []db.Group
+	q = d.Model(&db.Group{}).
+		Where("name in ?", outbounds).
+		Order("name").
+		Preload("PolicyParams").
+		Preload("Filters").
+		Preload("Subscription").
+		Preload("Subscription.Node").
+		Find(&groups)
+	if q.Error != nil {
+		return nil, q.Error
+	}
+
+	{
+		// Find not found.
+		nameSet := map[string]struct{}{}
+		for _, name := range outbounds {
+			nameSet[name] = struct{}{}
+		}
+		for _, g := range groups {
+			delete(nameSet, g.Name)
+		}
+		var notFound []string
+		for name := range nameSet {
+			switch name {
+			case "direct", "block", "must_rules":
+				// Preset groups.
+			default:
+				notFound = append(notFound, name)
+			}
+		}
+		sort.Strings(notFound)
+		for _, name := range notFound {
+			name := name
+			a.error(IssueGroupNotFound, &name, "group '%v' is not defined but referenced by routing '%v'", name, mRouting.Name)
+		}
+		var unused []string
+		if err = d.Model(&db.Group{}).Where("name not in ?", append(outbounds, "")).Order("name").Pluck("name", &unused).Error; err != nil {
+			return nil, err
+		}
+		for _, name := range unused {
+			name := name
+			a.warn(IssueUnusedGroup, &name, "group '%v' is not referenced by routing '%v' and will not be loaded", name, mRouting.Name)
+		}
+	}
+	// Find nodes in groups.
+	var nodes []*node
+	for i := range groups {
+		for _, gsub := range groups[i].Subscription {
+			if len(gsub.Node) == 0 {
+				a.warn(IssueEmptySubscription, &groups[i].Name, "subscription '%v' in group '%v' has no node", subscriptionName(&gsub), groups[i].Name)
+			}
+		}
+		members, err := group.Members(d, groups[i].ID, groups[i].Filters)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range members {
+			n := n
+			nodes = append(nodes, &node{
+				dbNode: &n,
+				groups: []*db.Group{&groups[i]},
+			})
+		}
+	}
+	nodes = deduplicateNodes(nodes)
+	uniquefyNodesName(nodes)
+	// Group -> nodes
+	mGroupNode := make(map[*db.Group]map[*node]struct{})
+	for i := range groups {
+		mGroupNode[&groups[i]] = make(map[*node]struct{})
+	}
+	for _, n := range nodes {
+		for _, group := range n.groups {
+			mGroupNode[group][n] = struct{}{}
+		}
+	}
+	// Fill in group section.
+	for i := range groups {
+		g := &groups[i]
+		sNodes := mGroupNode[g]
+		if len(sNodes) == 0 {
+			a.error(IssueEmptyGroup, &g.Name, "please add at least one node into group '%v' (referenced by current routing '%v')", g.Name, mRouting.Name)
+			continue
+		}
+		policy := group.DaePolicy(g)
+		// Node names to filter.
+		var names []*config_parser.Param
+		for node := range sNodes {
+			names = append(names, &config_parser.Param{
+				Val: node.uniqueName,
+			})
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return names[i].Val < names[j].Val
+		})
+		// The pinned node overrides the policy. Dialers of the group are in the order of the node section, which is
+		// sorted by names, so the index of the name is the index for fixed.
+		pinned := false
+		if g.PinnedNodeID != nil {
+			var link string
+			if err = d.Model(&db.Node{}).Where("id = ?", *g.PinnedNodeID).Select("link").Scan(&link).Error; err != nil {
+				return nil, err
+			}
+			for node := range sNodes {
+				if node.dbNode.Link != link {
+					continue
+				}
+				for j, name := range names {
+					if name.Val == node.uniqueName {
+						policy = group.PinnedPolicy(j)
+						pinned = true
+						break
+					}
+				}
+				break
+			}
+			if !pinned {
+				a.warn(IssuePinnedNodeNotInGroup, &g.Name, "the pinned node of group '%v' is not in the group and the pin is ignored", g.Name)
+			}
+		}
+		if !pinned {
+			var params []config_parser.Param
+			for _, param := range g.PolicyParams {
+				params = append(params, *param.Marshal())
+			}
+			if err := group.ValidatePolicy(g.Policy, params); err != nil {
+				a.error(IssueBadPolicy, &g.Name, "group '%v': %v", g.Name, err)
+			}
+		}
+		// fiexed group cannot have more than one node.
+		if g.Policy == "fixed" && !pinned && len(sNodes) > 1 {
+			a.error(IssueFixedGroupMultipleNodes, &g.Name, "group '%v' with policy 'fixed' cannot have more than one node", g.Name)
+		}
+		grp := daeConfig.Group{
+			Name: g.Name,
+			Filter: [][]*config_parser.Function{{{
+				Name:   "name",
+				Not:    false,
+				Params: names,
+			}}},
+			Policy: policy,
+		}
+		for range grp.Filter {
+			// Keep the same length as filter's.
+			grp.FilterAnnotation = append(grp.FilterAnnotation, []*config_parser.Param{})
+		}
+		c.Group = append(c.Group, grp)
+	}
+	// Fill in node section.
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].uniqueName < nodes[j].uniqueName
+	})
+	for _, node := range nodes {
+		c.Node = append(c.Node, daeConfig.KeyableString(fmt.Sprintf("%v:%v", node.uniqueName, node.dbNode.Link)))
+		var groupNames []string
+		for _, g := range node.groups {
+			groupNames = append(groupNames, g.Name)
+		}
+		sort.Strings(groupNames)
+		groupNames = daeCommon.Deduplicate(groupNames)
+		if wanted := baseNodeName(node.dbNode); node.uniqueName != wanted {
+			a.warn(IssueNodeRenamed, nil, "node '%v' is named '%v' in the generated config because of a name conflict", wanted, node.uniqueName)
+		}
+		a.Nodes = append(a.Nodes, &AssembledNode{
+			Name:   node.uniqueName,
+			Node:   node.dbNode,
+			Groups: groupNames,
+		})
+	}
+	a.Config = c
+	a.Groups = groups
+	return a, nil
+}
+
+func subscriptionName(s *db.Subscription) string {
+	if s.Tag != nil {
+		return *s.Tag
+	}
+	return string(common.EncodeCursor(s.ID))
+}
+
+// SectionNames are sections of the rendered config in order.
+var SectionNames = []string{"global", "dns", "routing", "group", "node"}
+
+// Sections renders sections of the assembly. Global, dns and routing are taken as stored; group and node are
+// rendered from the generated config.
+func Sections(a *Assembly) (map[string]string, error) {
+	marshaller := daeConfig.Marshaller{IndentSpace: 2}
+	if err := marshaller.MarshalSection("node", reflect.ValueOf(a.Config.Node), 0); err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"global":  strings.TrimSpace(a.Global.Global) + "\n",
+		"dns":     strings.TrimSpace(a.Dns.Dns) + "\n",
+		"routing": strings.TrimSpace(a.Routing.Routing) + "\n",
+		"group":   marshalGroups(a.Config.Group),
+		"node":    string(marshaller.Bytes()),
+	}, nil
+}
+
+// marshalGroups renders the group section. daeConfig.Marshaller cannot marshal filters.
+func marshalGroups(groups []daeConfig.Group) string {
+	var b strings.Builder
+	b.WriteString("group {\n")
+	for _, g := range groups {
+		b.WriteString("  " + g.Name + " {\n")
+		for _, filter := range g.Filter {
+			var fs []string
+			for _, f := range filter {
+				fs = append(fs, marshalFunction(f))
+			}
+			b.WriteString("    filter: " + strings.Join(fs, " && ") + "\n")
+		}
+		var policies []string
+		for _, f := range daeConfig.FunctionListOrStringToFunctionList(g.Policy) {
+			if len(f.Params) == 0 {
+				policies = append(policies, f.Name)
+			} else {
+				policies = append(policies, marshalFunction(f))
+			}
+		}
+		b.WriteString("    policy: " + strings.Join(policies, " && ") + "\n")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// marshalFunction is like config_parser.Function.String but never elides params.
+func marshalFunction(f *config_parser.Function) string {
+	var params []string
+	for _, p := range f.Params {
+		params = append(params, p.String(false, true))
+	}
+	s := f.Name + "(" + strings.Join(params, ", ") + ")"
+	if f.Not {
+		s = "!" + s
+	}
+	return s
+}
+
+// Render renders the assembly as a standalone dae config. Links of nodes are redacted if redactLinks is true.
+func Render(a *Assembly, redactLinks bool) (string, error) {
+	if redactLinks {
+		c := *a.Config
+		c.Node = make([]daeConfig.KeyableString, len(a.Config.Node))
+		for i, n := range a.Config.Node {
+			tag, link := daeCommon.GetTagFromLinkLikePlaintext(string(n))
+			c.Node[i] = daeConfig.KeyableString(tag + ":" + common.RedactLink(link))
+		}
+		redacted := *a
+		redacted.Config = &c
+		a = &redacted
+	}
+	sections, err := Sections(a)
+	if err != nil {
+		return "", err
+	}
+	return joinSections(sections), nil
+}
+
+func joinSections(sections map[string]string) string {
+	var rendered []string
+	for _, name := range SectionNames {
+		rendered = append(rendered, sections[name])
+	}
+	return strings.Join(rendered, "\n")
+}
@@ -0,0 +1,61 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+func TestSectionsGroupOrder(t *testing.T) {
+	if err := db.InitDatabase(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	d := db.DB(context.Background())
+	for _, m := range []interface{}{
+		&db.Config{Name: "global", Global: "global {}", Selected: true},
+		&db.Dns{Name: "dns", Dns: "dns {}", Selected: true},
+		&db.Routing{Name: "routing", Routing: "routing {\n  domain(example.com) -> alpha\n  fallback: zeta\n}", Selected: true},
+	} {
+		if err := d.Create(m).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Groups are created in non-alphabetical order.
+	for _, g := range []db.Group{
+		{Name: "zeta", Policy: "random", Node: []db.Node{{Link: "socks5://z.example:1080", Name: "z", Protocol: "socks5"}}},
+		{Name: "alpha", Policy: "random", Node: []db.Node{{Link: "socks5://a.example:1080", Name: "a", Protocol: "socks5"}}},
+	} {
+		if err := d.Create(&g).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := Assemble(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections, err := Sections(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `group {
+  alpha {
+    filter: name("a")
+    policy: random
+  }
+  zeta {
+    filter: name("z")
+    policy: random
+  }
+}
+`
+	if sections["group"] != want {
+		t.Fatalf("expected:\n%v\ngot:\n%v", want, sections["group"])
+	}
+}
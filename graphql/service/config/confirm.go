@@ -0,0 +1,279 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/dae"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/event"
+	daeConfig "github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	confirmMu    sync.Mutex
+	confirmTimer *time.Timer
+)
+
+// runningState is the running state recorded in db.System, kept to roll back an unconfirmed run.
+type runningState struct {
+	Running         bool
+	ConfigID        *uint
+	ConfigVersion   uint
+	DnsID           *uint
+	DnsVersion      uint
+	RoutingID       *uint
+	RoutingVersion  uint
+	GroupVersionSum uint
+	GroupIds        string
+	Groups          []uint
+	Sections        string
+}
+
+// snapshotState returns the running state recorded in sys.
+func snapshotState(d *gorm.DB, sys *db.System) (*runningState, error) {
+	if sys.Running && sys.RunningSections == "" {
+		return nil, fmt.Errorf("the running config was not recorded and cannot be rolled back to; run it again without confirmTimeout first")
+	}
+	var groups []uint
+	if sys.Running {
+		if err := d.Model(&db.Group{}).Where("system_id = ?", sys.ID).Pluck("id", &groups).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &runningState{
+		Running:         sys.Running,
+		ConfigID:        sys.RunningConfigID,
+		ConfigVersion:   sys.RunningConfigVersion,
+		DnsID:           sys.RunningDnsID,
+		DnsVersion:      sys.RunningDnsVersion,
+		RoutingID:       sys.RunningRoutingID,
+		RoutingVersion:  sys.RunningRoutingVersion,
+		GroupVersionSum: sys.RunningGroupVersionSum,
+		GroupIds:        sys.RunningGroupIds,
+		Groups:          groups,
+		Sections:        sys.RunningSections,
+	}, nil
+}
+
+// rollbackState returns the json of the state to roll back to if a run with confirmation times out.
+// If the running one is still unconfirmed, the last confirmed state is kept.
+func rollbackState(d *gorm.DB, sys *db.System) (string, error) {
+	if sys.RunConfirmDeadline != nil {
+		return sys.RollbackState, nil
+	}
+	state, err := snapshotState(d, sys)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// rollback reloads the state recorded in sys.RollbackState and records it as the running state.
+func rollback(d *gorm.DB, sys *db.System) (state *runningState, err error) {
+	state = &runningState{}
+	if err = json.Unmarshal([]byte(sys.RollbackState), state); err != nil {
+		return nil, fmt.Errorf("bad rollback state: %w", err)
+	}
+	if err = reloadState(state); err != nil {
+		return nil, err
+	}
+	if err = applyState(d, sys, state, nil, ""); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// reloadState loads the config of the state.
+func reloadState(state *runningState) (err error) {
+	c := dae.EmptyConfig
+	if state.Running {
+		var sections map[string]string
+		if err = json.Unmarshal([]byte(state.Sections), &sections); err != nil {
+			return fmt.Errorf("bad rollback state: %w", err)
+		}
+		parsed, err := config_parser.Parse(joinSections(sections))
+		if err != nil {
+			return err
+		}
+		if c, err = daeConfig.New(parsed); err != nil {
+			return err
+		}
+	}
+	if err = reload(c); err != nil {
+		return fmt.Errorf("failed to load the previous config: %w; see more in log", err)
+	}
+	return nil
+}
+
+// applyState records the state as the running state with given pending confirmation.
+func applyState(d *gorm.DB, sys *db.System, state *runningState, deadline *time.Time, rollbackState string) (err error) {
+	if err = d.Model(sys).Updates(map[string]interface{}{
+		"running":                   state.Running,
+		"running_config_id":         state.ConfigID,
+		"running_config_version":    state.ConfigVersion,
+		"running_dns_id":            state.DnsID,
+		"running_dns_version":       state.DnsVersion,
+		"running_routing_id":        state.RoutingID,
+		"running_routing_version":   state.RoutingVersion,
+		"running_group_version_sum": state.GroupVersionSum,
+		"running_group_ids":         state.GroupIds,
+		"running_sections":          state.Sections,
+		"run_confirm_deadline":      deadline,
+		"rollback_state":            rollbackState,
+	}).Error; err != nil {
+		return err
+	}
+	var groups []db.Group
+	if len(state.Groups) > 0 {
+		if err = d.Model(&db.Group{}).Where("id in ?", state.Groups).Find(&groups).Error; err != nil {
+			return err
+		}
+	}
+	return d.Model(sys).Association("RunningGroups").Replace(groups)
+}
+
+// resetConfirmTimer arms the timer to roll back at deadline, or stops it if deadline is nil.
+func resetConfirmTimer(deadline *time.Time) {
+	if deadline == nil {
+		stopConfirmTimer()
+	} else {
+		armConfirmTimer(*deadline)
+	}
+}
+
+func armConfirmTimer(deadline time.Time) {
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	if confirmTimer != nil {
+		confirmTimer.Stop()
+	}
+	confirmTimer = time.AfterFunc(time.Until(deadline), rollbackUnconfirmed)
+}
+
+func stopConfirmTimer() {
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	if confirmTimer != nil {
+		confirmTimer.Stop()
+		confirmTimer = nil
+	}
+}
+
+func rollbackUnconfirmed() {
+	runLock.Lock()
+	defer runLock.Unlock()
+	tx := db.BeginTx(context.Background())
+	var sys db.System
+	if err := tx.Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		tx.Rollback()
+		logrus.Errorln("Failed to roll back the unconfirmed run:", err)
+		return
+	}
+	if sys.RunConfirmDeadline == nil {
+		// Confirmed.
+		tx.Rollback()
+		return
+	}
+	if time.Now().Before(*sys.RunConfirmDeadline) {
+		tx.Rollback()
+		armConfirmTimer(*sys.RunConfirmDeadline)
+		return
+	}
+	state, err := rollback(tx, &sys)
+	if err != nil {
+		tx.Rollback()
+		logrus.Errorln("Failed to roll back the unconfirmed run:", err)
+		return
+	}
+	if err = tx.Commit().Error; err != nil {
+		logrus.Errorln("The unconfirmed run has been rolled back but failed to be recorded:", err)
+		return
+	}
+	event.PublishRunningState(state.Running)
+	logrus.Warnln("The last run was not confirmed in time and has been rolled back")
+}
+
+// ConfirmRun keeps the last run so that it will not be rolled back.
+func ConfirmRun(ctx context.Context) (n int32, err error) {
+	runLock.Lock()
+	defer runLock.Unlock()
+	tx := db.BeginTx(ctx)
+	if err = confirmRun(tx); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	stopConfirmTimer()
+	return 1, nil
+}
+
+func confirmRun(d *gorm.DB) error {
+	var sys db.System
+	if err := d.Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		return err
+	}
+	if sys.RunConfirmDeadline == nil {
+		return fmt.Errorf("no run is waiting for confirmation")
+	}
+	return d.Model(&sys).Updates(map[string]interface{}{
+		"run_confirm_deadline": nil,
+		"rollback_state":       "",
+	}).Error
+}
+
+// RestoreUnconfirmed handles a run left unconfirmed by the last process. It rolls back if the deadline has passed,
+// or otherwise loads the recorded config of the unconfirmed run again for the rest of the time. ok is false if there
+// is no such run.
+func RestoreUnconfirmed(ctx context.Context) (ok bool, err error) {
+	runLock.Lock()
+	defer runLock.Unlock()
+	var sys db.System
+	if err = db.DB(ctx).Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		return false, err
+	}
+	if sys.RunConfirmDeadline == nil {
+		return false, nil
+	}
+	if time.Now().Before(*sys.RunConfirmDeadline) {
+		// The selected config may have been changed after the run, so load what the run recorded.
+		state, err := snapshotState(db.DB(ctx), &sys)
+		if err != nil {
+			return true, err
+		}
+		if err = reloadState(state); err != nil {
+			return true, err
+		}
+		armConfirmTimer(*sys.RunConfirmDeadline)
+		event.PublishRunningState(state.Running)
+		return true, nil
+	}
+	tx := db.BeginTx(ctx)
+	state, err := rollback(tx, &sys)
+	if err != nil {
+		tx.Rollback()
+		return true, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return true, err
+	}
+	event.PublishRunningState(state.Running)
+	return true, nil
+}
@@ -0,0 +1,55 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package config
+
+import (
+	"encoding/json"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/pkg/udiff"
+	"gorm.io/gorm"
+)
+
+const diffContext = 3
+
+type SectionDiffResolver struct {
+	Section string
+	Diff    string
+}
+
+// RunningDiff compares what Run would load now with what the last run loaded, section by section.
+// Only changed sections are returned.
+func RunningDiff(d *gorm.DB) (rs []*SectionDiffResolver, err error) {
+	var sys db.System
+	if err = d.Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		return nil, err
+	}
+	running := map[string]string{}
+	if sys.RunningSections != "" {
+		if err = json.Unmarshal([]byte(sys.RunningSections), &running); err != nil {
+			return nil, err
+		}
+	}
+	a, err := Assemble(d)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := Sections(a)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range SectionNames {
+		diff := udiff.Unified("running/"+name, "selected/"+name, running[name], selected[name], diffContext)
+		if diff == "" {
+			continue
+		}
+		rs = append(rs, &SectionDiffResolver{
+			Section: name,
+			Diff:    diff,
+		})
+	}
+	return rs, nil
+}
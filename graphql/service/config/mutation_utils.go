@@ -7,25 +7,30 @@ package config
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/daeuniverse/dae-wing/common"
 	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
 	"github.com/daeuniverse/dae-wing/graphql/service/config/global"
+	"github.com/daeuniverse/dae-wing/graphql/service/event"
+	"github.com/daeuniverse/dae-wing/graphql/service/probe"
+	"github.com/daeuniverse/dae-wing/graphql/service/revision"
+	"github.com/daeuniverse/dae-wing/metrics"
 	daeConfig "github.com/daeuniverse/dae/config"
-	"github.com/daeuniverse/dae/pkg/config_parser"
 	"github.com/graph-gophers/graphql-go"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func Create(ctx context.Context, name string, glob *global.Input) (*Resolver, error) {
+func Create(ctx context.Context, name string, glob *global.Input) (r *Resolver, err error) {
 	if glob == nil {
 		glob = &global.Input{}
 	}
@@ -33,41 +38,54 @@ func Create(ctx context.Context, name string, glob *global.Input) (*Resolver, er
 	if err != nil {
 		return nil, err
 	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	return Insert(ctx, tx, name, strGlobal)
+}
+
+// Insert creates a global config from a complete global section using given db.
+func Insert(ctx context.Context, d *gorm.DB, name string, section string) (r *Resolver, err error) {
+	r, _, err = insert(ctx, d, name, section, nil)
+	return r, err
+}
+
+func insert(ctx context.Context, d *gorm.DB, name string, section string, restoredFrom *uint) (r *Resolver, rev *db.Revision, err error) {
 	m := db.Config{
 		ID:       0,
 		Name:     name,
-		Global:   strGlobal,
+		Global:   section,
 		Selected: false,
 	}
 	// Check grammar and to dae config.
 	c, err := dae.ParseConfig(&m.Global, nil, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if err = db.DB(ctx).Create(&m).Error; err != nil {
-		return nil, err
+	if err = d.Create(&m).Error; err != nil {
+		return nil, nil, err
+	}
+	if rev, err = revision.Record(ctx, d, db.RevisionKindConfig, m.ID, m.Version, m.Global, restoredFrom); err != nil {
+		return nil, nil, err
 	}
 	return &Resolver{
 		DaeGlobal: &c.Global,
 		Model:     &m,
-	}, nil
+	}, rev, nil
 }
 
-func Update(ctx context.Context, _id graphql.ID, inputGlobal global.Input) (*Resolver, error) {
+func Update(ctx context.Context, _id graphql.ID, inputGlobal global.Input) (r *Resolver, err error) {
 	id, err := common.DecodeCursor(_id)
 	if err != nil {
 		return nil, err
 	}
-	tx := db.BeginTx(ctx)
-	defer func() {
-		if err == nil {
-			tx.Commit()
-		} else {
-			tx.Rollback()
-		}
-	}()
 	var m db.Config
-	if err = tx.Model(&db.Config{}).Where("id = ?", id).First(&m).Error; err != nil {
+	if err = db.DB(ctx).Model(&db.Config{}).Where("id = ?", id).First(&m).Error; err != nil {
 		return nil, err
 	}
 	// Prepare to partially update.
@@ -88,17 +106,59 @@ func Update(ctx context.Context, _id graphql.ID, inputGlobal global.Input) (*Res
 	if err = marshaller.MarshalSection("global", reflect.ValueOf(c.Global), 0); err != nil {
 		return nil, err
 	}
+	r, _, err = update(ctx, id, string(marshaller.Bytes()), nil)
+	return r, err
+}
+
+// RevisionTarget restores revisions of global configs.
+var RevisionTarget = revision.Target{
+	Insert: func(ctx context.Context, d *gorm.DB, name string, content string, restoredFrom *uint) (*db.Revision, error) {
+		_, rev, err := insert(ctx, d, name, content, restoredFrom)
+		return rev, err
+	},
+	Update: func(ctx context.Context, id uint, content string, restoredFrom *uint) (*db.Revision, error) {
+		_, rev, err := update(ctx, id, content, restoredFrom)
+		return rev, err
+	},
+}
+
+func update(ctx context.Context, id uint, content string, restoredFrom *uint) (r *Resolver, rev *db.Revision, err error) {
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	var m db.Config
+	if err = tx.Model(&db.Config{}).Where("id = ?", id).First(&m).Error; err != nil {
+		return nil, nil, err
+	}
+	if err = revision.EnsureBaseline(tx, db.RevisionKindConfig, m.ID, m.Version, m.Global); err != nil {
+		return nil, nil, err
+	}
+	m.Global = content
+	// Parse it to check the grammar.
+	c, err := dae.ParseConfig(&m.Global, nil, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad config: %w", err)
+	}
 	// Update.
 	if err = tx.Model(&db.Config{ID: id}).Updates(map[string]interface{}{
-		"global":  string(marshaller.Bytes()),
+		"global":  m.Global,
 		"version": gorm.Expr("version + 1"),
 	}).Error; err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	m.Version++
+	if rev, err = revision.Record(ctx, tx, db.RevisionKindConfig, m.ID, m.Version, m.Global, restoredFrom); err != nil {
+		return nil, nil, err
 	}
 	return &Resolver{
 		DaeGlobal: &c.Global,
 		Model:     &m,
-	}, nil
+	}, rev, nil
 }
 
 func Remove(ctx context.Context, _id graphql.ID) (n int32, err error) {
@@ -114,6 +174,17 @@ func Remove(ctx context.Context, _id graphql.ID) (n int32, err error) {
 			tx.Rollback()
 		}
 	}()
+	// Keep the history in case the removal is a mistake.
+	var names []string
+	if err = tx.Model(&db.Config{}).Where("id = ?", id).Pluck("name", &names).Error; err != nil {
+		return 0, err
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+	if err = revision.Detach(tx, db.RevisionKindConfig, id, names[0]); err != nil {
+		return 0, err
+	}
 	m := db.Config{ID: id}
 	q := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "selected"}}}).
 		Select(clause.Associations).
@@ -159,6 +230,18 @@ func normNodeName(_name string) string {
 	return string(ret)
 }
 
+// baseNodeName is the name of the node in the generated config if there is no conflict.
+func baseNodeName(n *db.Node) string {
+	if n.Tag != nil {
+		return *n.Tag
+	}
+	baseName := normNodeName(n.Name)
+	if n.SubscriptionID != nil {
+		baseName = fmt.Sprintf("%v.%v", *n.SubscriptionID, baseName)
+	}
+	return baseName
+}
+
 func uniquefyNodesName(nodes []*node) {
 	// Uniquefy names of nodes.
 	// Sort nodes by "has node.Tag" because node.Tag is unique but names of others may be the same with them.
@@ -171,11 +254,8 @@ func uniquefyNodesName(nodes []*node) {
 		if node.dbNode.Tag != nil {
 			nameToNodes[*node.dbNode.Tag] = node
 		} else {
-			baseName := normNodeName(node.dbNode.Name)
-			if node.dbNode.SubscriptionID != nil {
-				baseName = fmt.Sprintf("%v.%v", *node.dbNode.SubscriptionID, baseName)
-			}
 			// SubID.Name
+			baseName := baseNodeName(node.dbNode)
 			wantedName := baseName
 			for j := 0; ; j++ {
 				_, exist := nameToNodes[wantedName]
@@ -237,208 +317,113 @@ func Rename(ctx context.Context, _id graphql.ID, name string) (n int32, err erro
 
 var runLock sync.Mutex
 
-func Run(d *gorm.DB, noLoad bool) (n int32, err error) {
+// runResult is what a run recorded, and the state to load back if anything fails afterwards.
+type runResult struct {
+	sys         db.System
+	previous    *runningState
+	errPrevious error
+	sections    string
+	deadline    *time.Time
+	// Confirmation of the previous run.
+	previousDeadline      *time.Time
+	previousRollbackState string
+}
+
+// Run loads the selected config, or stops dae if noLoad is true. If confirmTimeout is positive, the previous running
+// state is restored unless ConfirmRun is called within the timeout. Probes are checked after the new running state is
+// saved, and the previous one is loaded back and saved again if they fail and rollback on failure is enabled.
+func Run(ctx context.Context, noLoad bool, confirmTimeout time.Duration) (n int32, err error) {
 	if ok := runLock.TryLock(); !ok {
 		return 0, fmt.Errorf("the last request didn't complete; make a cup of tea and take a break")
 	}
 	defer runLock.Unlock()
-	//// Dry run.
-	if noLoad {
-		ch := make(chan error)
-		dae.ChReloadConfigs <- &dae.ReloadMessage{
-			Config:   dae.EmptyConfig,
-			Callback: ch,
-		}
-		err = <-ch
-		if err != nil {
-			return 0, fmt.Errorf("failed to dryrun: %w; see more in log and report bugs", err)
-		}
-
-		// Running -> false
-		var sys db.System
-		if err = d.Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
-			return 0, err
-		}
-		if err = d.Model(&sys).Updates(map[string]interface{}{
-			"running": false,
-		}).Error; err != nil {
-			return 0, err
-		}
-		return 1, nil
-	}
-
-	//// Run selected global+dns+routing.
-	/// Get them from database and parse them to daeConfig.
-	var mConfig db.Config
-	var mDns db.Dns
-	var mRouting db.Routing
-	q := d.Model(&db.Config{}).Where("selected = ?", true).First(&mConfig)
-	if (q.Error == nil && q.RowsAffected == 0) || errors.Is(q.Error, gorm.ErrRecordNotFound) {
-		return 0, fmt.Errorf("please select a config")
-	}
-	if q.Error != nil {
-		return 0, q.Error
-	}
-	q = d.Model(&db.Dns{}).Where("selected = ?", true).First(&mDns)
-	if (q.Error == nil && q.RowsAffected == 0) || errors.Is(q.Error, gorm.ErrRecordNotFound) {
-		return 0, fmt.Errorf("please select a dns")
-	}
-	if q.Error != nil {
-		return 0, q.Error
-	}
-	q = d.Model(&db.Routing{}).Where("selected = ?", true).First(&mRouting)
-	if (q.Error == nil && q.RowsAffected == 0) || errors.Is(q.Error, gorm.ErrRecordNotFound) {
-		return 0, fmt.Errorf("please select a routing")
-	}
-	if q.Error != nil {
-		return 0, q.Error
-	}
-	c, err := dae.ParseConfig(&mConfig.Global, &mDns.Dns, &mRouting.Routing)
+	tx := db.BeginTx(ctx)
+	r, err := run(tx, noLoad, confirmTimeout)
 	if err != nil {
+		tx.Rollback()
 		return 0, err
 	}
-	/// Fill in necessary groups and nodes.
-	// Find groups needed by routing.
-	outbounds := dae.NecessaryOutbounds(&c.Routing)
-	var groups []db.Group
-	q = d.Model(&db.Group{}).
-		Where("name in ?", outbounds).
-		Preload("PolicyParams").
-		Preload("Subscription").
-		Preload("Subscription.Node").
-		Find(&groups)
-	if q.Error != nil {
-		return 0, q.Error
+	if err = tx.Commit().Error; err != nil {
+		return 0, r.undo(err)
 	}
-
-	{
-		// Find not found.
-		nameSet := map[string]struct{}{}
-		for _, name := range outbounds {
-			nameSet[name] = struct{}{}
-		}
-		for _, g := range groups {
-			delete(nameSet, g.Name)
-		}
-		var notFound []string
-		for name := range nameSet {
-			switch name {
-			case "direct", "block", "must_rules":
-				// Preset groups.
-			default:
-				notFound = append(notFound, name)
-			}
-		}
-		if len(notFound) > 0 {
-			return 0, fmt.Errorf("groups not defined but referenced by routing: %v", strings.Join(notFound, ", "))
-		}
+	resetConfirmTimer(r.deadline)
+	event.PublishRunningState(!noLoad)
+	if noLoad {
+		return 1, nil
 	}
-	// Find nodes in groups.
-	var nodes []*node
-	for i := range groups {
-		for _, gsub := range groups[i].Subscription {
-			for _, n := range gsub.Node {
-				n := n
-				nodes = append(nodes, &node{
-					dbNode: &n,
-					groups: []*db.Group{&groups[i]},
-				})
-			}
-		}
-		var solitaryNodes []db.Node
-		if err = d.Model(groups[i]).
-			Association("Node").
-			Find(&solitaryNodes); err != nil {
-			return 0, err
-		}
-		for _, n := range solitaryNodes {
-			n := n
-			nodes = append(nodes, &node{
-				dbNode: &n,
-				groups: []*db.Group{&groups[i]},
-			})
-		}
+	if err = probeAfterRun(ctx, r); err != nil {
+		return 0, err
 	}
-	nodes = deduplicateNodes(nodes)
-	uniquefyNodesName(nodes)
-	// Group -> nodes
-	mGroupNode := make(map[*db.Group]map[*node]struct{})
-	for i := range groups {
-		mGroupNode[&groups[i]] = make(map[*node]struct{})
+	return 1, nil
+}
+
+// run loads the config and records the running state using d, which should be a transaction.
+func run(d *gorm.DB, noLoad bool, confirmTimeout time.Duration) (r *runResult, err error) {
+	r = &runResult{}
+	if err = d.Model(&db.System{}).FirstOrCreate(&r.sys).Error; err != nil {
+		return nil, err
 	}
-	for _, n := range nodes {
-		for _, group := range n.groups {
-			mGroupNode[group][n] = struct{}{}
+	r.previousDeadline, r.previousRollbackState = r.sys.RunConfirmDeadline, r.sys.RollbackState
+	var strRollbackState string
+	if confirmTimeout > 0 {
+		if strRollbackState, err = rollbackState(d, &r.sys); err != nil {
+			return nil, err
 		}
+		t := time.Now().Add(confirmTimeout)
+		r.deadline = &t
 	}
-	// Fill in group section.
-	for g, sNodes := range mGroupNode {
-		if len(sNodes) == 0 {
-			return 0, fmt.Errorf("please add at least one node into group '%v' (referenced by current routing '%v')", g.Name, mRouting.Name)
+	// The running state before this run, to load back if anything fails after loading the new config.
+	r.previous, r.errPrevious = snapshotState(d, &r.sys)
+
+	//// Dry run.
+	if noLoad {
+		if err = reload(dae.EmptyConfig); err != nil {
+			return nil, fmt.Errorf("failed to dryrun: %w; see more in log and report bugs", err)
 		}
-		// Parse policy.
-		var policy daeConfig.FunctionListOrString
-		if len(g.PolicyParams) == 0 {
-			policy = g.Policy
-		} else {
-			// Parse policy params.
-			var params []*config_parser.Param
-			for _, param := range g.PolicyParams {
-				params = append(params, param.Marshal())
-			}
-			policy = &config_parser.Function{
-				Name:   g.Policy,
-				Not:    false,
-				Params: params,
+		defer func() {
+			if err != nil {
+				err = r.undo(err)
 			}
+		}()
+		// Running -> false
+		if err = d.Model(&r.sys).Updates(map[string]interface{}{
+			"running":              false,
+			"running_sections":     "",
+			"run_confirm_deadline": r.deadline,
+			"rollback_state":       strRollbackState,
+		}).Error; err != nil {
+			return nil, err
 		}
-		// fiexed group cannot have more than one node.
-		if g.Policy == "fixed" && len(sNodes) > 1 {
-			return 0, fmt.Errorf("group '%v' with policy 'fixed' cannot have more than one node", g.Name)
-		}
-		// Node names to filter.
-		var names []*config_parser.Param
-		for node := range sNodes {
-			names = append(names, &config_parser.Param{
-				Val: node.uniqueName,
-			})
-		}
-		grp := daeConfig.Group{
-			Name: g.Name,
-			Filter: [][]*config_parser.Function{{{
-				Name:   "name",
-				Not:    false,
-				Params: names,
-			}}},
-			Policy: policy,
-		}
-		for range grp.Filter {
-			// Keep the same length as filter's.
-			grp.FilterAnnotation = append(grp.FilterAnnotation, []*config_parser.Param{})
-		}
-		c.Group = append(c.Group, grp)
+		return r, nil
 	}
-	// Fill in node section.
-	for _, node := range nodes {
-		c.Node = append(c.Node, daeConfig.KeyableString(fmt.Sprintf("%v:%v", node.uniqueName, node.dbNode.Link)))
+
+	a, err := Assemble(d)
+	if err != nil {
+		return nil, err
 	}
+	c, groups := a.Config, a.Groups
+	mConfig, mDns, mRouting := a.Global, a.Dns, a.Routing
+	sections, err := Sections(a)
+	if err != nil {
+		return nil, err
+	}
+	bSections, err := json.Marshal(sections)
+	if err != nil {
+		return nil, err
+	}
+	r.sections = string(bSections)
 
 	/// Reload with current config.
-	chReloadCallback := make(chan error)
-	dae.ChReloadConfigs <- &dae.ReloadMessage{
-		Config:   c,
-		Callback: chReloadCallback,
-	}
-	errReload := <-chReloadCallback
-	if errReload != nil {
-		return 0, fmt.Errorf("failed to load new config: %w; see more in log", errReload)
+	if errReload := reload(c); errReload != nil {
+		return nil, fmt.Errorf("failed to load new config: %w; see more in log", errReload)
 	}
+	defer func() {
+		if err != nil {
+			err = r.undo(err)
+		}
+	}()
 
 	// Save running status
-	var sys db.System
-	if err = d.Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
-		return 0, err
-	}
 	var gvs uint
 	var gids []string
 	for _, g := range groups {
@@ -448,7 +433,7 @@ func Run(d *gorm.DB, noLoad bool) (n int32, err error) {
 	sort.Slice(gids, func(i, j int) bool {
 		return gids[i] < gids[j]
 	})
-	if err = d.Model(&sys).Updates(map[string]interface{}{
+	if err = d.Model(&r.sys).Updates(map[string]interface{}{
 		"running":                   true,
 		"running_config_id":         mConfig.ID,
 		"running_config_version":    mConfig.Version,
@@ -458,12 +443,93 @@ func Run(d *gorm.DB, noLoad bool) (n int32, err error) {
 		"running_routing_version":   mRouting.Version,
 		"running_group_version_sum": gvs,
 		"running_group_ids":         strings.Join(gids, ","),
+		"running_sections":          r.sections,
+		"run_confirm_deadline":      r.deadline,
+		"rollback_state":            strRollbackState,
 	}).Error; err != nil {
-		return 0, err
+		return nil, err
 	}
-	if err = d.Model(&sys).Association("RunningGroups").Replace(groups); err != nil {
-		return 0, err
+	if err = d.Model(&r.sys).Association("RunningGroups").Replace(groups); err != nil {
+		return nil, err
 	}
+	return r, nil
+}
 
-	return 1, nil
+// undo loads the previous config back because the new one failed to be recorded.
+func (r *runResult) undo(err error) error {
+	if r.errPrevious != nil {
+		return fmt.Errorf("%w; the new config is loaded but not recorded, and cannot be rolled back: %v", err, r.errPrevious)
+	}
+	if e := reloadState(r.previous); e != nil {
+		return fmt.Errorf("%w; failed to roll back: %v", err, e)
+	}
+	return fmt.Errorf("%w; the previous config has been loaded back", err)
+}
+
+// reload loads c into dae and publishes the progress.
+func reload(c *daeConfig.Config) error {
+	event.PublishReload(event.ReloadStateStarted, nil)
+	start := time.Now()
+	ch := make(chan error)
+	dae.ChReloadConfigs <- &dae.ReloadMessage{
+		Config:   c,
+		Callback: ch,
+	}
+	err := <-ch
+	metrics.ObserveReload(time.Since(start), err)
+	if err != nil {
+		event.PublishReload(event.ReloadStateFailed, err)
+		return err
+	}
+	event.PublishReload(event.ReloadStateFinished, nil)
+	return nil
+}
+
+// probeAfterRun checks probes through the new control plane. If any fails and rollback on failure is enabled, the
+// previous config is loaded back and recorded as the running state again, and an error is returned.
+func probeAfterRun(ctx context.Context, r *runResult) (err error) {
+	d := db.DB(ctx)
+	results, err := probe.Run(d)
+	if err != nil {
+		return err
+	}
+	probe.SetLast(results)
+	failed := probe.Failed(results)
+	if len(failed) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, f := range failed {
+		msgs = append(msgs, fmt.Sprintf("%v %v: %v", f.Kind(), f.Target(), *f.Error()))
+	}
+	strFailed := strings.Join(msgs, "; ")
+	rollbackOnFailure, err := probe.RollbackOnFailure(d)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !rollbackOnFailure:
+		logrus.Warnln("Probes failed after run:", strFailed)
+		return nil
+	case r.errPrevious != nil:
+		logrus.Warnf("Probes failed after run but cannot roll back: %v: %v", r.errPrevious, strFailed)
+		return nil
+	case r.previous.Running && r.previous.Sections == r.sections:
+		logrus.Warnln("Probes failed after run but the previous config is the same:", strFailed)
+		return nil
+	}
+	if err = reloadState(r.previous); err != nil {
+		return fmt.Errorf("probes failed: %v; failed to roll back: %w", strFailed, err)
+	}
+	tx := db.BeginTx(ctx)
+	if err = applyState(tx, &r.sys, r.previous, r.previousDeadline, r.previousRollbackState); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("probes failed: %v; the previous config has been loaded back but failed to be recorded: %w", strFailed, err)
+	}
+	if err = tx.Commit().Error; err != nil {
+		return fmt.Errorf("probes failed: %v; the previous config has been loaded back but failed to be recorded: %w", strFailed, err)
+	}
+	resetConfirmTimer(r.previousDeadline)
+	event.PublishRunningState(r.previous.Running)
+	return fmt.Errorf("probes failed: %v; the previous config has been loaded back", strFailed)
 }
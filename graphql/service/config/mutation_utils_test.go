@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/revision"
+)
+
+func TestRestoreRemoved(t *testing.T) {
+	if err := db.InitDatabase(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	d := db.DB(ctx)
+	r, err := Insert(ctx, d, "home", "global {\n  log_level: info\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = update(ctx, r.Model.ID, "global {\n  log_level: debug\n}", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Remove(ctx, common.EncodeCursor(r.Model.ID)); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := revision.ListRemoved(ctx, db.RevisionKindConfig, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("expected 2 revisions of the removed config, got %v", len(removed))
+	}
+	// Restore the first revision.
+	first := removed[1].Revision
+
+	restored, err := revision.Restore(ctx, first, RevisionTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configs []db.Config
+	if err = d.Find(&configs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs[0].Name != "home" || configs[0].Global != first.Content {
+		t.Fatalf("unexpected configs after restoring: %+v", configs)
+	}
+	// The restored config takes over the history.
+	history, err := revision.List(ctx, db.RevisionKindConfig, common.EncodeCursor(restored.TargetID), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 revisions of the restored config, got %v", len(history))
+	}
+
+	// Restoring the same revision again updates the restored config rather than creating another.
+	if first, err = revision.Get(ctx, common.EncodeCursor(first.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = revision.Restore(ctx, first, RevisionTarget); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Find(&configs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs[0].Version != 1 {
+		t.Fatalf("expected the restored config to be updated, got %+v", configs)
+	}
+}
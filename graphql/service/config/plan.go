@@ -0,0 +1,69 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package config
+
+import (
+	"github.com/daeuniverse/dae-wing/graphql/service/group"
+	nodeService "github.com/daeuniverse/dae-wing/graphql/service/node"
+)
+
+type PlanResolver struct {
+	*Assembly
+}
+
+func (r *PlanResolver) Errors() []*Issue {
+	return append([]*Issue{}, r.Assembly.Errors...)
+}
+
+func (r *PlanResolver) Warnings() []*Issue {
+	return append([]*Issue{}, r.Assembly.Warnings...)
+}
+
+func (r *PlanResolver) Outbounds() []string {
+	return append([]string{}, r.Assembly.Outbounds...)
+}
+
+func (r *PlanResolver) Groups() []*PlannedGroupResolver {
+	members := make(map[string][]string)
+	for _, n := range r.Assembly.Nodes {
+		for _, g := range n.Groups {
+			members[g] = append(members[g], n.Name)
+		}
+	}
+	rs := make([]*PlannedGroupResolver, 0, len(r.Assembly.Groups))
+	for i := range r.Assembly.Groups {
+		g := &r.Assembly.Groups[i]
+		rs = append(rs, &PlannedGroupResolver{
+			Group: &group.Resolver{Group: g},
+			Nodes: append([]string{}, members[g.Name]...),
+		})
+	}
+	return rs
+}
+
+func (r *PlanResolver) Nodes() []*PlannedNodeResolver {
+	rs := make([]*PlannedNodeResolver, 0, len(r.Assembly.Nodes))
+	for _, n := range r.Assembly.Nodes {
+		rs = append(rs, &PlannedNodeResolver{
+			Name:   n.Name,
+			Node:   &nodeService.Resolver{Node: n.Node},
+			Groups: n.Groups,
+		})
+	}
+	return rs
+}
+
+type PlannedGroupResolver struct {
+	Group *group.Resolver
+	// Nodes are names of member nodes in the generated config.
+	Nodes []string
+}
+
+type PlannedNodeResolver struct {
+	Name   string
+	Node   *nodeService.Resolver
+	Groups []string
+}
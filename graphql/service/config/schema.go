@@ -13,5 +13,55 @@ type Config {
 	global: Global!
 	selected: Boolean!
 }
+
+enum PlanIssueCode {
+	CONFIG_NOT_SELECTED
+	DNS_NOT_SELECTED
+	ROUTING_NOT_SELECTED
+	BAD_CONFIG
+	GROUP_NOT_FOUND
+	EMPTY_GROUP
+	FIXED_GROUP_MULTIPLE_NODES
+	UNUSED_GROUP
+	EMPTY_SUBSCRIPTION
+	NODE_RENAMED
+	PINNED_NODE_NOT_IN_GROUP
+	BAD_POLICY
+}
+
+type PlanIssue {
+	code: PlanIssueCode!
+	message: String!
+	# group is the name of the group concerned, if any.
+	group: String
+}
+
+type Plan {
+	# errors prevent the config from being run.
+	errors: [PlanIssue!]!
+	warnings: [PlanIssue!]!
+	# outbounds are referenced by the routing.
+	outbounds: [String!]!
+	groups: [PlannedGroup!]!
+	nodes: [PlannedNode!]!
+}
+
+type PlannedGroup {
+	group: Group!
+	# nodes are names of member nodes in the generated config.
+	nodes: [String!]!
+}
+
+type PlannedNode {
+	# name is the unique name in the generated config.
+	name: String!
+	node: Node!
+	groups: [String!]!
+}
+
+type SectionDiff {
+	section: String!
+	diff: String!
+}
 `, nil
 }
@@ -12,31 +12,52 @@ import (
 	"github.com/daeuniverse/dae-wing/common"
 	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/revision"
 	"github.com/graph-gophers/graphql-go"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func Create(ctx context.Context, name string, dns string) (*Resolver, error) {
-	dns = "dns {\n" + dns + "\n}"
+func Create(ctx context.Context, name string, dns string) (r *Resolver, err error) {
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	return Insert(ctx, tx, name, "dns {\n"+dns+"\n}")
+}
+
+// Insert creates a dns config from a complete dns section using given db.
+func Insert(ctx context.Context, d *gorm.DB, name string, section string) (r *Resolver, err error) {
+	r, _, err = insert(ctx, d, name, section, nil)
+	return r, err
+}
+
+func insert(ctx context.Context, d *gorm.DB, name string, section string, restoredFrom *uint) (r *Resolver, rev *db.Revision, err error) {
 	m := db.Dns{
 		ID:       0,
 		Name:     name,
-		Dns:      dns,
+		Dns:      section,
 		Selected: false,
 	}
 	// Check grammar and to dae config.
 	c, err := dae.ParseConfig(nil, &m.Dns, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if err = db.DB(ctx).Create(&m).Error; err != nil {
-		return nil, err
+	if err = d.Create(&m).Error; err != nil {
+		return nil, nil, err
+	}
+	if rev, err = revision.Record(ctx, d, db.RevisionKindDns, m.ID, m.Version, m.Dns, restoredFrom); err != nil {
+		return nil, nil, err
 	}
 	return &Resolver{
 		DaeDns: &c.Dns,
 		Model:  &m,
-	}, nil
+	}, rev, nil
 }
 
 func Update(ctx context.Context, _id graphql.ID, dns string) (*Resolver, error) {
@@ -44,6 +65,23 @@ func Update(ctx context.Context, _id graphql.ID, dns string) (*Resolver, error)
 	if err != nil {
 		return nil, err
 	}
+	r, _, err := update(ctx, id, "dns {\n"+dns+"\n}", nil)
+	return r, err
+}
+
+// RevisionTarget restores revisions of dnss.
+var RevisionTarget = revision.Target{
+	Insert: func(ctx context.Context, d *gorm.DB, name string, content string, restoredFrom *uint) (*db.Revision, error) {
+		_, rev, err := insert(ctx, d, name, content, restoredFrom)
+		return rev, err
+	},
+	Update: func(ctx context.Context, id uint, content string, restoredFrom *uint) (*db.Revision, error) {
+		_, rev, err := update(ctx, id, content, restoredFrom)
+		return rev, err
+	},
+}
+
+func update(ctx context.Context, id uint, content string, restoredFrom *uint) (r *Resolver, rev *db.Revision, err error) {
 	tx := db.BeginTx(ctx)
 	defer func() {
 		if err == nil {
@@ -54,26 +92,32 @@ func Update(ctx context.Context, _id graphql.ID, dns string) (*Resolver, error)
 	}()
 	var m db.Dns
 	if err = tx.Model(&db.Dns{}).Where("id = ?", id).First(&m).Error; err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if err = revision.EnsureBaseline(tx, db.RevisionKindDns, m.ID, m.Version, m.Dns); err != nil {
+		return nil, nil, err
 	}
-	// Prepare to partially update.
-	m.Dns = "dns {\n" + dns + "\n}"
-	// Parse it check the grammar.
+	m.Dns = content
+	// Parse it to check the grammar.
 	c, err := dae.ParseConfig(nil, &m.Dns, nil)
 	if err != nil {
-		return nil, fmt.Errorf("bad current dns: %w", err)
+		return nil, nil, fmt.Errorf("bad current dns: %w", err)
 	}
 	// Update.
 	if err = tx.Model(&db.Dns{ID: id}).Updates(map[string]interface{}{
 		"dns":     m.Dns,
 		"version": gorm.Expr("version + 1"),
 	}).Error; err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	m.Version++
+	if rev, err = revision.Record(ctx, tx, db.RevisionKindDns, m.ID, m.Version, m.Dns, restoredFrom); err != nil {
+		return nil, nil, err
 	}
 	return &Resolver{
 		DaeDns: &c.Dns,
 		Model:  &m,
-	}, nil
+	}, rev, nil
 }
 
 func Remove(ctx context.Context, _id graphql.ID) (n int32, err error) {
@@ -89,6 +133,17 @@ func Remove(ctx context.Context, _id graphql.ID) (n int32, err error) {
 			tx.Rollback()
 		}
 	}()
+	// Keep the history in case the removal is a mistake.
+	var names []string
+	if err = tx.Model(&db.Dns{}).Where("id = ?", id).Pluck("name", &names).Error; err != nil {
+		return 0, err
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+	if err = revision.Detach(tx, db.RevisionKindDns, id, names[0]); err != nil {
+		return 0, err
+	}
 	m := db.Dns{ID: id}
 	q := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "selected"}}}).
 		Select(clause.Associations).
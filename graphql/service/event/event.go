@@ -0,0 +1,131 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	ReloadStateStarted  = "STARTED"
+	ReloadStateFinished = "FINISHED"
+	ReloadStateFailed   = "FAILED"
+)
+
+// bufferSize is how many events a subscriber can fall behind. Further events are dropped for it.
+const bufferSize = 16
+
+var (
+	reloads             Broker[*ReloadResolver]
+	subscriptionUpdates Broker[*SubscriptionUpdateResolver]
+	runningStates       Broker[*RunningStateResolver]
+
+	runningMu   sync.Mutex
+	lastRunning *bool
+)
+
+// Broker delivers published values to subscribers. The zero value is ready to use.
+type Broker[T any] struct {
+	mu   sync.Mutex
+	subs map[chan T]struct{}
+}
+
+// Subscribe returns a channel of values published until ctx is done, when the channel is closed.
+func (b *Broker[T]) Subscribe(ctx context.Context) <-chan T {
+	ch := make(chan T, bufferSize)
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = map[chan T]struct{}{}
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, ch)
+		close(ch)
+		b.mu.Unlock()
+	}()
+	return ch
+}
+
+// Publish delivers e to subscribers without blocking. Subscribers falling behind miss it.
+func (b *Broker[T]) Publish(e T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func now() graphql.Time {
+	return graphql.Time{Time: time.Now()}
+}
+
+func errorString(err error) *string {
+	if err == nil {
+		return nil
+	}
+	s := err.Error()
+	return &s
+}
+
+// PublishReload publishes a state of reloading dae. err is the reason of ReloadStateFailed.
+func PublishReload(state string, err error) {
+	reloads.Publish(&ReloadResolver{
+		State: state,
+		Error: errorString(err),
+		At:    now(),
+	})
+}
+
+// PublishSubscriptionUpdate publishes the result of updating a subscription.
+func PublishSubscriptionUpdate(id uint, tag *string, err error) {
+	subscriptionUpdates.Publish(&SubscriptionUpdateResolver{
+		SubscriptionId: common.EncodeCursor(id),
+		Tag:            tag,
+		Ok:             err == nil,
+		Error:          errorString(err),
+		At:             now(),
+	})
+}
+
+// PublishRunningState publishes the running state if it differs from the last published one.
+func PublishRunningState(running bool) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if lastRunning != nil && *lastRunning == running {
+		return
+	}
+	lastRunning = &running
+	runningStates.Publish(&RunningStateResolver{
+		Running: running,
+		At:      now(),
+	})
+}
+
+// Reloads returns a channel of reload events until ctx is done.
+func Reloads(ctx context.Context) <-chan *ReloadResolver {
+	return reloads.Subscribe(ctx)
+}
+
+// SubscriptionUpdates returns a channel of subscription update events until ctx is done.
+func SubscriptionUpdates(ctx context.Context) <-chan *SubscriptionUpdateResolver {
+	return subscriptionUpdates.Subscribe(ctx)
+}
+
+// RunningStates returns a channel of running state changes until ctx is done.
+func RunningStates(ctx context.Context) <-chan *RunningStateResolver {
+	return runningStates.Subscribe(ctx)
+}
@@ -0,0 +1,29 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package event
+
+import (
+	"github.com/graph-gophers/graphql-go"
+)
+
+type ReloadResolver struct {
+	State string
+	Error *string
+	At    graphql.Time
+}
+
+type SubscriptionUpdateResolver struct {
+	SubscriptionId graphql.ID
+	Tag            *string
+	Ok             bool
+	Error          *string
+	At             graphql.Time
+}
+
+type RunningStateResolver struct {
+	Running bool
+	At      graphql.Time
+}
@@ -0,0 +1,33 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package event
+
+func Schema() (string, error) {
+	return `
+enum ReloadState {
+	STARTED
+	FINISHED
+	# FAILED means dae failed to load the new config and kept the previous one.
+	FAILED
+}
+type ReloadEvent {
+	state: ReloadState!
+	error: String
+	at: Time!
+}
+type SubscriptionUpdateEvent {
+	subscriptionId: ID!
+	tag: String
+	ok: Boolean!
+	error: String
+	at: Time!
+}
+type RunningStateEvent {
+	running: Boolean!
+	at: Time!
+}
+`, nil
+}
@@ -11,7 +11,11 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/logs"
+	"github.com/daeuniverse/dae-wing/graphql/service/probe"
+	"github.com/graph-gophers/graphql-go"
 )
 
 type DaeResolver struct {
@@ -77,6 +81,26 @@ func (r *DaeResolver) Modified() (bool, error) {
 	return false, nil
 }
 
+func (r *DaeResolver) ConfirmDeadline() (*graphql.Time, error) {
+	var m db.System
+	q := db.DB(r.Ctx).Select("run_confirm_deadline").Model(&db.System{}).FirstOrCreate(&m)
+	if q.Error != nil {
+		return nil, q.Error
+	}
+	if m.RunConfirmDeadline == nil {
+		return nil, nil
+	}
+	return &graphql.Time{Time: *m.RunConfirmDeadline}, nil
+}
+
+func (r *DaeResolver) Probes() []*probe.Result {
+	return probe.Last()
+}
+
+func (r *DaeResolver) LogLevel() string {
+	return logs.LevelName(dae.LogLevel())
+}
+
 func (r *DaeResolver) Version() string {
 	return db.AppVersion
 }
@@ -16,6 +16,11 @@ type Dae {
   running: Boolean!
   # modified indicates whether the running config has been modified.
   modified: Boolean!
+  # confirmDeadline is when the last run will be rolled back unless it is confirmed.
+  confirmDeadline: Time
+  # probes are results of probes checked after the last run.
+  probes: [RunProbeResult!]!
+  logLevel: LogLevel!
   version: String!
 }
 type Interface {
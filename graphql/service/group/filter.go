@@ -0,0 +1,253 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package group
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/graph-gophers/graphql-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FilterInput struct {
+	Kind    string
+	Value   string
+	Exclude *bool
+}
+
+type FilterResolver struct {
+	db.GroupFilter
+}
+
+func (r *FilterResolver) Kind() string {
+	return r.GroupFilter.Kind
+}
+
+func (r *FilterResolver) Value() string {
+	return r.GroupFilter.Value
+}
+
+func (r *FilterResolver) Exclude() bool {
+	return r.GroupFilter.Exclude
+}
+
+// ParseFilters checks filters and converts them to models.
+func ParseFilters(inputs []FilterInput) ([]db.GroupFilter, error) {
+	filters := make([]db.GroupFilter, 0, len(inputs))
+	for _, in := range inputs {
+		f := db.GroupFilter{
+			Kind:    in.Kind,
+			Value:   in.Value,
+			Exclude: in.Exclude != nil && *in.Exclude,
+		}
+		if f.Value == "" {
+			return nil, fmt.Errorf("empty value of filter %v", f.Kind)
+		}
+		if _, err := newMatcher([]db.GroupFilter{f}); err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// matcher matches nodes against filters of a group.
+type matcher struct {
+	include []func(n *db.Node, subTag string) bool
+	exclude []func(n *db.Node, subTag string) bool
+}
+
+func newMatcher(filters []db.GroupFilter) (*matcher, error) {
+	m := &matcher{}
+	for _, f := range filters {
+		value := f.Value
+		var match func(n *db.Node, subTag string) bool
+		switch f.Kind {
+		case db.GroupFilterKeyword:
+			match = func(n *db.Node, _ string) bool {
+				return strings.Contains(n.Name, value)
+			}
+		case db.GroupFilterRegex:
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad regex of filter: %w", err)
+			}
+			match = func(n *db.Node, _ string) bool {
+				return re.MatchString(n.Name)
+			}
+		case db.GroupFilterProtocol:
+			match = func(n *db.Node, _ string) bool {
+				return strings.EqualFold(n.Protocol, value)
+			}
+		case db.GroupFilterSubscriptionTag:
+			match = func(_ *db.Node, subTag string) bool {
+				return subTag == value
+			}
+		case db.GroupFilterTagPrefix:
+			match = func(n *db.Node, _ string) bool {
+				return n.Tag != nil && strings.HasPrefix(*n.Tag, value)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected filter kind: %v", f.Kind)
+		}
+		if f.Exclude {
+			m.exclude = append(m.exclude, match)
+		} else {
+			m.include = append(m.include, match)
+		}
+	}
+	return m, nil
+}
+
+func matchAny(fs []func(n *db.Node, subTag string) bool, n *db.Node, subTag string) bool {
+	for _, match := range fs {
+		if match(n, subTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Members resolves nodes of the group with given filters: nodes added to it, nodes of its subscriptions and nodes
+// matching any filter. Nodes matching any exclusion are left out, except nodes added to the group one by one.
+func Members(d *gorm.DB, id uint, filters []db.GroupFilter) (nodes []db.Node, err error) {
+	m, err := newMatcher(filters)
+	if err != nil {
+		return nil, err
+	}
+	var added []db.Node
+	if err = d.Model(&db.Group{ID: id}).Association("Node").Find(&added); err != nil {
+		return nil, err
+	}
+	var candidates []db.Node
+	q := d.Model(&db.Node{})
+	if len(m.include) == 0 {
+		q = q.Where("subscription_id in (?)",
+			d.Table("group_subscriptions").Select("subscription_id").Where("group_id = ?", id))
+	}
+	if err = q.Order("id").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	var subIds []uint
+	if err = d.Table("group_subscriptions").Where("group_id = ?", id).Pluck("subscription_id", &subIds).Error; err != nil {
+		return nil, err
+	}
+	inSubscription := make(map[uint]struct{}, len(subIds))
+	for _, subId := range subIds {
+		inSubscription[subId] = struct{}{}
+	}
+	var subs []db.Subscription
+	if err = d.Model(&db.Subscription{}).Where("tag is not null").Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	subTags := make(map[uint]string, len(subs))
+	for _, s := range subs {
+		subTags[s.ID] = *s.Tag
+	}
+
+	set := make(map[uint]struct{})
+	for _, n := range added {
+		set[n.ID] = struct{}{}
+		nodes = append(nodes, n)
+	}
+	for i := range candidates {
+		n := &candidates[i]
+		if _, ok := set[n.ID]; ok {
+			continue
+		}
+		var subTag string
+		joined := false
+		if n.SubscriptionID != nil {
+			subTag = subTags[*n.SubscriptionID]
+			_, joined = inSubscription[*n.SubscriptionID]
+		}
+		if !joined && !matchAny(m.include, n, subTag) {
+			continue
+		}
+		if matchAny(m.exclude, n, subTag) {
+			continue
+		}
+		set[n.ID] = struct{}{}
+		nodes = append(nodes, *n)
+	}
+	return nodes, nil
+}
+
+// SetFilters replaces filters of the group.
+func SetFilters(ctx context.Context, _id graphql.ID, inputs []FilterInput) (n int32, err error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return 0, err
+	}
+	filters, err := ParseFilters(inputs)
+	if err != nil {
+		return 0, err
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	var m db.Group
+	if err = tx.Model(&db.Group{}).Where("id = ?", id).First(&m).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Where("group_id = ?", id).Delete(&db.GroupFilter{}).Error; err != nil {
+		return 0, err
+	}
+	if len(filters) > 0 {
+		for i := range filters {
+			filters[i].GroupID = id
+		}
+		if err = tx.Omit(clause.Associations).Create(&filters).Error; err != nil {
+			return 0, err
+		}
+	}
+	if err = autoUpdateVersionById(tx, id); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// PreviewMembers resolves nodes of the group as a run would do now. Given filters are used instead of the group's if
+// they are not nil.
+func PreviewMembers(ctx context.Context, _id graphql.ID, inputs *[]FilterInput) (rs []*node.Resolver, err error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return nil, err
+	}
+	d := db.DB(ctx)
+	var filters []db.GroupFilter
+	if inputs != nil {
+		if filters, err = ParseFilters(*inputs); err != nil {
+			return nil, err
+		}
+	} else if err = d.Where("group_id = ?", id).Order("id").Find(&filters).Error; err != nil {
+		return nil, err
+	}
+	if err = d.Model(&db.Group{}).Where("id = ?", id).First(&db.Group{}).Error; err != nil {
+		return nil, err
+	}
+	nodes, err := Members(d, id, filters)
+	if err != nil {
+		return nil, err
+	}
+	rs = make([]*node.Resolver, 0, len(nodes))
+	for i := range nodes {
+		rs = append(rs, &node.Resolver{Node: &nodes[i]})
+	}
+	return rs, nil
+}
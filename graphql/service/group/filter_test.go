@@ -0,0 +1,91 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package group
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+func TestMembers(t *testing.T) {
+	if err := db.InitDatabase(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	d := db.DB(context.Background())
+	tag := func(s string) *string { return &s }
+	hk := db.Subscription{Link: "https://hk.example/sub", Tag: tag("hk-sub"), Node: []db.Node{
+		{Link: "vmess://hk1", Name: "HK 01", Protocol: "vmess"},
+		{Link: "trojan://hk2", Name: "HK 02 expire", Protocol: "trojan"},
+	}}
+	us := db.Subscription{Link: "https://us.example/sub", Tag: tag("us-sub"), Node: []db.Node{
+		{Link: "vmess://us1", Name: "US 01", Protocol: "vmess"},
+		{Link: "ss://us2", Name: "US 02", Protocol: "shadowsocks"},
+	}}
+	for _, s := range []*db.Subscription{&hk, &us} {
+		if err := d.Create(s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	jp := db.Node{Link: "vmess://jp1", Name: "JP 01", Protocol: "vmess", Tag: tag("jp-1")}
+	sg := db.Node{Link: "trojan://sg1", Name: "SG 01", Protocol: "trojan", Tag: tag("sg-1")}
+	for _, n := range []*db.Node{&jp, &sg} {
+		if err := d.Create(n).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := db.Group{Name: "proxy", Policy: "random", Node: []db.Node{sg}, Subscription: []db.Subscription{hk}}
+	if err := d.Create(&g).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	include := func(kind, value string) db.GroupFilter { return db.GroupFilter{Kind: kind, Value: value} }
+	exclude := func(kind, value string) db.GroupFilter { return db.GroupFilter{Kind: kind, Value: value, Exclude: true} }
+	tests := []struct {
+		name    string
+		filters []db.GroupFilter
+		want    []string
+		wantErr bool
+	}{
+		{name: "added nodes and subscriptions", want: []string{"SG 01", "HK 01", "HK 02 expire"}},
+		{name: "keyword", filters: []db.GroupFilter{include(db.GroupFilterKeyword, "US")},
+			want: []string{"SG 01", "HK 01", "HK 02 expire", "US 01", "US 02"}},
+		{name: "regex", filters: []db.GroupFilter{include(db.GroupFilterRegex, "^(US|JP) 01$")},
+			want: []string{"SG 01", "HK 01", "HK 02 expire", "US 01", "JP 01"}},
+		{name: "protocol ignores case", filters: []db.GroupFilter{include(db.GroupFilterProtocol, "VMESS")},
+			want: []string{"SG 01", "HK 01", "HK 02 expire", "US 01", "JP 01"}},
+		{name: "subscription tag", filters: []db.GroupFilter{include(db.GroupFilterSubscriptionTag, "us-sub")},
+			want: []string{"SG 01", "HK 01", "HK 02 expire", "US 01", "US 02"}},
+		{name: "tag prefix", filters: []db.GroupFilter{include(db.GroupFilterTagPrefix, "jp")},
+			want: []string{"SG 01", "HK 01", "HK 02 expire", "JP 01"}},
+		{name: "exclusion applies to subscriptions", filters: []db.GroupFilter{exclude(db.GroupFilterKeyword, "expire")},
+			want: []string{"SG 01", "HK 01"}},
+		{name: "exclusion keeps added nodes", filters: []db.GroupFilter{exclude(db.GroupFilterKeyword, "SG")},
+			want: []string{"SG 01", "HK 01", "HK 02 expire"}},
+		{name: "inclusion and exclusion", filters: []db.GroupFilter{
+			include(db.GroupFilterKeyword, "01"),
+			exclude(db.GroupFilterProtocol, "vmess"),
+		}, want: []string{"SG 01", "HK 02 expire"}},
+		{name: "bad regex", filters: []db.GroupFilter{include(db.GroupFilterRegex, "(")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Members(d, g.ID, tt.filters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var names []string
+			for _, n := range nodes {
+				names = append(names, n.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, names)
+			}
+		})
+	}
+}
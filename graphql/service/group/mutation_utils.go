@@ -16,9 +16,17 @@ import (
 )
 
 func Create(ctx context.Context, name string, policy string, policyParams []config_parser.Param) (r *Resolver, err error) {
+	return Insert(db.DB(ctx), name, policy, policyParams)
+}
+
+// Insert creates a group using given db.
+func Insert(d *gorm.DB, name string, policy string, policyParams []config_parser.Param) (r *Resolver, err error) {
 	if err = common.ValidateId(name); err != nil {
 		return nil, err
 	}
+	if err = ValidatePolicy(policy, policyParams); err != nil {
+		return nil, err
+	}
 	params := make([]db.GroupPolicyParam, len(policyParams))
 	for i := range params {
 		params[i].Unmarshal(&policyParams[i])
@@ -29,7 +37,7 @@ func Create(ctx context.Context, name string, policy string, policyParams []conf
 		Policy:       policy,
 		PolicyParams: params,
 	}
-	if err = db.DB(ctx).Create(&m).Error; err != nil {
+	if err = d.Create(&m).Error; err != nil {
 		return nil, err
 	}
 	return &Resolver{
@@ -105,6 +113,9 @@ func Remove(ctx context.Context, _id graphql.ID) (n int32, err error) {
 	if err = tx.Where("group_id = ?", id).Delete(&db.GroupPolicyParam{}).Error; err != nil {
 		return 0, err
 	}
+	if err = tx.Where("group_id = ?", id).Delete(&db.GroupFilter{}).Error; err != nil {
+		return 0, err
+	}
 	if err = autoUpdateVersionById(tx, g.ID); err != nil {
 		return 0, err
 	}
@@ -248,6 +259,9 @@ func SetPolicy(ctx context.Context, _id graphql.ID, policy string, policyParams
 	if err != nil {
 		return 0, err
 	}
+	if err = ValidatePolicy(policy, policyParams); err != nil {
+		return 0, err
+	}
 	tx := db.BeginTx(ctx)
 	defer func() {
 		if err == nil {
@@ -0,0 +1,180 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/dae"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/component/outbound"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+	daeConfig "github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"github.com/graph-gophers/graphql-go"
+	"gorm.io/gorm"
+)
+
+// DaePolicy is the policy of the group in dae config. PolicyParams of m should be loaded.
+func DaePolicy(m *db.Group) daeConfig.FunctionListOrString {
+	if len(m.PolicyParams) == 0 {
+		return m.Policy
+	}
+	var params []*config_parser.Param
+	for _, param := range m.PolicyParams {
+		params = append(params, param.Marshal())
+	}
+	return &config_parser.Function{
+		Name:   m.Policy,
+		Not:    false,
+		Params: params,
+	}
+}
+
+// PinnedPolicy is the policy of a group pinned to the node at the index of its nodes.
+func PinnedPolicy(index int) daeConfig.FunctionListOrString {
+	return &config_parser.Function{
+		Name:   string(consts.DialerSelectionPolicy_Fixed),
+		Not:    false,
+		Params: []*config_parser.Param{{Val: fmt.Sprint(index)}},
+	}
+}
+
+// Pin makes the group always choose the node, which should be a member of the group. It takes effect in the running
+// control plane immediately and is kept in the generated group section.
+func Pin(ctx context.Context, _id graphql.ID, _nodeId graphql.ID) (n int32, err error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return 0, err
+	}
+	nodeId, err := common.DecodeCursor(_nodeId)
+	if err != nil {
+		return 0, err
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	var m db.Group
+	if err = tx.Model(&db.Group{}).Where("id = ?", id).First(&m).Error; err != nil {
+		return 0, err
+	}
+	var node db.Node
+	if err = tx.Model(&db.Node{}).Where("id = ?", nodeId).First(&node).Error; err != nil {
+		return 0, err
+	}
+	isMember, err := hasNode(tx, id, nodeId)
+	if err != nil {
+		return 0, err
+	}
+	if !isMember {
+		return 0, fmt.Errorf("node %v is not in group %v", _nodeId, m.Name)
+	}
+	if err = tx.Model(&m).Update("pinned_node_id", nodeId).Error; err != nil {
+		return 0, err
+	}
+
+	g, err := liveGroup(m.Name)
+	if err != nil || g == nil {
+		return 1, err
+	}
+	for i, d := range g.Dialers {
+		if d.Property().Link == node.Link {
+			g.SetSelectionPolicy(outbound.DialerSelectionPolicy{
+				Policy:     consts.DialerSelectionPolicy_Fixed,
+				FixedIndex: i,
+			})
+			return 1, nil
+		}
+	}
+	return 0, fmt.Errorf("node %v is not loaded in group %v; run to apply the membership first", _nodeId, m.Name)
+}
+
+// Unpin restores the policy of the group. If the running control plane loaded the group pinned, the policy takes
+// effect at the next run and the running config is reported modified until then.
+func Unpin(ctx context.Context, _id graphql.ID) (n int32, err error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return 0, err
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	var m db.Group
+	if err = tx.Model(&db.Group{}).Where("id = ?", id).Preload("PolicyParams").First(&m).Error; err != nil {
+		return 0, err
+	}
+	if m.PinnedNodeID == nil {
+		return 0, nil
+	}
+	if err = tx.Model(&m).Update("pinned_node_id", nil).Error; err != nil {
+		return 0, err
+	}
+
+	g, err := liveGroup(m.Name)
+	if err != nil || g == nil {
+		return 1, err
+	}
+	policy, err := outbound.NewDialerSelectionPolicyFromGroupParam(&daeConfig.Group{
+		Name:   m.Name,
+		Policy: DaePolicy(&m),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if policy.Policy != consts.DialerSelectionPolicy_Fixed &&
+		g.MustGetAliveDialerSet(&dialer.NetworkType{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_4}) == nil {
+		// The group was loaded with the pin and does not track alive state for the policy, so the policy cannot be
+		// switched in place. Report the unpin as pending with modified until the next run.
+		if err = autoUpdateVersionById(tx, id); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+	g.SetSelectionPolicy(*policy)
+	return 1, nil
+}
+
+// hasNode reports whether the node is a member of the group as a run would resolve it.
+func hasNode(d *gorm.DB, id uint, nodeId uint) (bool, error) {
+	var filters []db.GroupFilter
+	if err := d.Where("group_id = ?", id).Order("id").Find(&filters).Error; err != nil {
+		return false, err
+	}
+	members, err := Members(d, id, filters)
+	if err != nil {
+		return false, err
+	}
+	for _, n := range members {
+		if n.ID == nodeId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// liveGroup returns the dialer group in the running control plane, or nil if dae is not running or the group is not
+// loaded.
+func liveGroup(name string) (*outbound.DialerGroup, error) {
+	g, err := dae.DialerGroup(name)
+	if errors.Is(err, dae.ErrControlPlaneNotInit) {
+		return nil, nil
+	}
+	return g, err
+}
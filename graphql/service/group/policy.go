@@ -0,0 +1,102 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package group
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+)
+
+const PolicyParamTypeInt = "INT"
+
+type PolicyDescription struct {
+	Policy      string
+	Description string
+	Params      []*PolicyParamDescription
+}
+
+type PolicyParamDescription struct {
+	// Key is nil if the param is given by position.
+	Key         *string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// PolicyDescriptions are policies supported by dae, in the order of the Policy enum.
+var PolicyDescriptions = []*PolicyDescription{
+	{
+		Policy:      string(consts.DialerSelectionPolicy_Random),
+		Description: "Choose a random alive node for every connection.",
+	},
+	{
+		Policy:      string(consts.DialerSelectionPolicy_Fixed),
+		Description: "Always choose the node at the index, no matter whether it is alive.",
+		Params: []*PolicyParamDescription{{
+			Type:        PolicyParamTypeInt,
+			Required:    true,
+			Description: "Index of the node in the group, starting from 0.",
+		}},
+	},
+	{
+		Policy:      string(consts.DialerSelectionPolicy_MinAverage10Latencies),
+		Description: "Choose the alive node with the minimum average of the last 10 latencies.",
+	},
+	{
+		Policy:      string(consts.DialerSelectionPolicy_MinMovingAverageLatencies),
+		Description: "Choose the alive node with the minimum moving average of latencies.",
+	},
+	{
+		Policy:      string(consts.DialerSelectionPolicy_MinLastLatency),
+		Description: "Choose the alive node with the minimum last latency.",
+	},
+}
+
+// ValidatePolicy checks the policy and its params the same way dae does when the group is loaded.
+func ValidatePolicy(policy string, params []config_parser.Param) error {
+	var desc *PolicyDescription
+	for _, d := range PolicyDescriptions {
+		if d.Policy == policy {
+			desc = d
+			break
+		}
+	}
+	if desc == nil {
+		return fmt.Errorf("unsupported policy: %v", policy)
+	}
+	if len(params) > len(desc.Params) {
+		if len(desc.Params) == 0 {
+			return fmt.Errorf("policy %v takes no parameter but got %v", policy, len(params))
+		}
+		return fmt.Errorf("policy %v takes at most %v parameter(s) but got %v", policy, len(desc.Params), len(params))
+	}
+	for i, pd := range desc.Params {
+		if i >= len(params) {
+			if pd.Required {
+				return fmt.Errorf("policy %v requires parameter %v: %v", policy, i+1, pd.Description)
+			}
+			continue
+		}
+		p := params[i]
+		switch {
+		case pd.Key == nil && p.Key != "":
+			return fmt.Errorf("parameter %v of policy %v should have no key but got %q", i+1, policy, p.Key)
+		case pd.Key != nil && p.Key != *pd.Key:
+			return fmt.Errorf("parameter %v of policy %v should have key %q but got %q", i+1, policy, *pd.Key, p.Key)
+		}
+		switch pd.Type {
+		case PolicyParamTypeInt:
+			v, err := strconv.Atoi(p.Val)
+			if err != nil || v < 0 {
+				return fmt.Errorf("parameter %v of policy %v should be a non-negative integer but got %q", i+1, policy, p.Val)
+			}
+		}
+	}
+	return nil
+}
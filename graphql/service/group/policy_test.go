@@ -0,0 +1,38 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package group
+
+import (
+	"testing"
+
+	"github.com/daeuniverse/dae/pkg/config_parser"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		params  []config_parser.Param
+		wantErr bool
+	}{
+		{name: "no params", policy: "min_moving_avg"},
+		{name: "unsupported policy", policy: "round_robin", wantErr: true},
+		{name: "unexpected param", policy: "random", params: []config_parser.Param{{Val: "0"}}, wantErr: true},
+		{name: "fixed", policy: "fixed", params: []config_parser.Param{{Val: "2"}}},
+		{name: "fixed without index", policy: "fixed", wantErr: true},
+		{name: "fixed with negative index", policy: "fixed", params: []config_parser.Param{{Val: "-1"}}, wantErr: true},
+		{name: "fixed with non-integer index", policy: "fixed", params: []config_parser.Param{{Val: "a"}}, wantErr: true},
+		{name: "fixed with keyed index", policy: "fixed", params: []config_parser.Param{{Key: "index", Val: "0"}}, wantErr: true},
+		{name: "fixed with two indexes", policy: "fixed", params: []config_parser.Param{{Val: "0"}, {Val: "1"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePolicy(tt.policy, tt.params); (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -60,3 +60,33 @@ func (r *Resolver) PolicyParams() (rs []*internal.ParamResolver, err error) {
 	}
 	return rs, nil
 }
+
+func (r *Resolver) Filters() (rs []*FilterResolver, err error) {
+	var filters []db.GroupFilter
+	if err = db.DB(context.TODO()).Where("group_id = ?", r.Group.ID).Order("id").Find(&filters).Error; err != nil {
+		return nil, err
+	}
+	for _, f := range filters {
+		rs = append(rs, &FilterResolver{GroupFilter: f})
+	}
+	return rs, nil
+}
+
+func (r *Resolver) PinnedNode() (*node.Resolver, error) {
+	if r.Group.PinnedNodeID == nil {
+		return nil, nil
+	}
+	var m db.Node
+	q := db.DB(context.TODO()).Model(&db.Node{}).Where("id = ?", *r.Group.PinnedNodeID).Limit(1).Find(&m)
+	if q.Error != nil {
+		return nil, q.Error
+	}
+	if q.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &node.Resolver{Node: &m}, nil
+}
+
+func (r *Resolver) Runtime() (*RuntimeResolver, error) {
+	return Runtime(context.TODO(), r.Group.Name)
+}
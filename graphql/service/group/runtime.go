@@ -0,0 +1,171 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package group
+
+import (
+	"context"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/scalar"
+	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae/common/consts"
+	"github.com/daeuniverse/dae/component/outbound"
+	"github.com/daeuniverse/dae/component/outbound/dialer"
+)
+
+// networkTypes are network types that dae checks connectivity of, in the order of the output.
+var networkTypes = []dialer.NetworkType{
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_4},
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_6},
+	{L4Proto: consts.L4ProtoStr_UDP, IpVersion: consts.IpVersionStr_4},
+	{L4Proto: consts.L4ProtoStr_UDP, IpVersion: consts.IpVersionStr_6},
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_4, IsDns: true},
+	{L4Proto: consts.L4ProtoStr_TCP, IpVersion: consts.IpVersionStr_6, IsDns: true},
+}
+
+type RuntimeResolver struct {
+	group *outbound.DialerGroup
+	nodes []*RuntimeNodeResolver
+}
+
+type RuntimeNodeResolver struct {
+	dialer *dialer.Dialer
+	node   *db.Node
+}
+
+type NetworkStateResolver struct {
+	dialer      *dialer.Dialer
+	networkType dialer.NetworkType
+}
+
+type SelectionResolver struct {
+	networkType dialer.NetworkType
+	node        *RuntimeNodeResolver
+	err         *string
+}
+
+// Runtime reads the state of the group from the running control plane. It returns nil if dae is not running or the
+// group is not loaded.
+func Runtime(ctx context.Context, name string) (*RuntimeResolver, error) {
+	g, err := liveGroup(name)
+	if err != nil || g == nil {
+		return nil, err
+	}
+	// Dialers are built from links in the generated node section, so we find nodes by links.
+	links := make([]string, 0, len(g.Dialers))
+	for _, d := range g.Dialers {
+		links = append(links, d.Property().Link)
+	}
+	var models []db.Node
+	if err = db.DB(ctx).Model(&db.Node{}).Where("link in ?", links).Order("id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	linkToNode := make(map[string]*db.Node, len(models))
+	for i := range models {
+		if _, ok := linkToNode[models[i].Link]; !ok {
+			linkToNode[models[i].Link] = &models[i]
+		}
+	}
+	r := &RuntimeResolver{group: g}
+	for _, d := range g.Dialers {
+		r.nodes = append(r.nodes, &RuntimeNodeResolver{
+			dialer: d,
+			node:   linkToNode[d.Property().Link],
+		})
+	}
+	return r, nil
+}
+
+func (r *RuntimeResolver) Policy() string {
+	return string(r.group.GetSelectionPolicy())
+}
+
+func (r *RuntimeResolver) Nodes() []*RuntimeNodeResolver {
+	return r.nodes
+}
+
+func (r *RuntimeResolver) Selected() []*SelectionResolver {
+	rs := make([]*SelectionResolver, 0, 4)
+	for _, typ := range networkTypes {
+		if typ.IsDns {
+			continue
+		}
+		s := &SelectionResolver{networkType: typ}
+		// Select may change the ip version of the given network type.
+		selectType := typ
+		d, _, err := r.group.Select(&selectType, true)
+		if err != nil {
+			info := err.Error()
+			s.err = &info
+		}
+		for _, n := range r.nodes {
+			if n.dialer == d {
+				s.node = n
+				break
+			}
+		}
+		rs = append(rs, s)
+	}
+	return rs
+}
+
+func (r *RuntimeNodeResolver) Name() string {
+	return r.dialer.Property().Name
+}
+
+func (r *RuntimeNodeResolver) Node() *node.Resolver {
+	if r.node == nil {
+		return nil
+	}
+	return &node.Resolver{Node: r.node}
+}
+
+func (r *RuntimeNodeResolver) Networks() []*NetworkStateResolver {
+	rs := make([]*NetworkStateResolver, 0, len(networkTypes))
+	for _, typ := range networkTypes {
+		rs = append(rs, &NetworkStateResolver{
+			dialer:      r.dialer,
+			networkType: typ,
+		})
+	}
+	return rs
+}
+
+func (r *NetworkStateResolver) Network() string {
+	return r.networkType.String()
+}
+
+func (r *NetworkStateResolver) Alive() bool {
+	return r.dialer.MustGetAlive(&r.networkType)
+}
+
+func (r *NetworkStateResolver) LastLatency() *scalar.Duration {
+	latency, ok := r.dialer.MustGetLatencies10(&r.networkType).LastLatency()
+	if !ok {
+		return nil
+	}
+	return &scalar.Duration{Duration: latency}
+}
+
+func (r *NetworkStateResolver) AvgLatency() *scalar.Duration {
+	latency, ok := r.dialer.MustGetLatencies10(&r.networkType).AvgLatency()
+	if !ok {
+		return nil
+	}
+	return &scalar.Duration{Duration: latency}
+}
+
+func (r *SelectionResolver) Network() string {
+	return r.networkType.String()
+}
+
+func (r *SelectionResolver) Node() *RuntimeNodeResolver {
+	return r.node
+}
+
+func (r *SelectionResolver) Error() *string {
+	return r.err
+}
@@ -14,6 +14,76 @@ type Group {
 	subscriptions: [Subscription!]!
 	policy: Policy!
 	policyParams: [Param!]!
+	# filters add matching nodes to the group when it is loaded, besides nodes and subscriptions added to it.
+	filters: [GroupFilter!]!
+	# pinnedNode is the node that the group always chooses regardless of the policy, if any.
+	pinnedNode: Node
+	# runtime is the state in the running control plane. It is null if dae is not running or the group is not loaded.
+	runtime: GroupRuntime
+}
+type GroupRuntime {
+	policy: Policy!
+	nodes: [GroupRuntimeNode!]!
+	# selected are nodes chosen by the policy for each network type.
+	selected: [GroupSelection!]!
+}
+type GroupRuntimeNode {
+	# name is the name in the generated config.
+	name: String!
+	node: Node
+	networks: [NodeNetworkState!]!
+}
+type NodeNetworkState {
+	# network is like tcp4, udp6 or tcp4(DNS).
+	network: String!
+	alive: Boolean!
+	lastLatency: Duration
+	# avgLatency is the average of the last 10 latencies.
+	avgLatency: Duration
+}
+type GroupSelection {
+	network: String!
+	node: GroupRuntimeNode
+	error: String
+}
+type GroupFilter {
+	kind: GroupFilterKind!
+	value: String!
+	exclude: Boolean!
+}
+input GroupFilterInput {
+	kind: GroupFilterKind!
+	value: String!
+	# exclude leaves matching nodes out of the group unless they are added to it one by one.
+	exclude: Boolean
+}
+enum GroupFilterKind {
+	# KEYWORD matches names of nodes containing the value.
+	KEYWORD
+	# REGEX matches names of nodes with the regular expression.
+	REGEX
+	# PROTOCOL matches nodes of the protocol, e.g. vmess.
+	PROTOCOL
+	# SUBSCRIPTION_TAG matches nodes of the subscription with the tag.
+	SUBSCRIPTION_TAG
+	# TAG_PREFIX matches nodes whose tags start with the value.
+	TAG_PREFIX
+}
+type PolicyDescription {
+	policy: Policy!
+	description: String!
+	# params are in the order to be given.
+	params: [PolicyParamDescription!]!
+}
+type PolicyParamDescription {
+	# key is null if the param is given without a key.
+	key: String
+	type: PolicyParamType!
+	required: Boolean!
+	description: String!
+}
+enum PolicyParamType {
+	INT
 }
 enum Policy {
 	random
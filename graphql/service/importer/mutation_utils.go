@@ -0,0 +1,331 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/dae"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/internal"
+	"github.com/daeuniverse/dae-wing/graphql/service/config"
+	"github.com/daeuniverse/dae-wing/graphql/service/dns"
+	"github.com/daeuniverse/dae-wing/graphql/service/group"
+	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae-wing/graphql/service/routing"
+	"github.com/daeuniverse/dae-wing/graphql/service/subscription"
+	daeCommon "github.com/daeuniverse/dae/common"
+	daeConfig "github.com/daeuniverse/dae/config"
+	"github.com/daeuniverse/dae/pkg/config_parser"
+	"gorm.io/gorm"
+)
+
+const DefaultName = "imported"
+
+// policies are policies a group can have in dae-wing.
+var policies = map[string]struct{}{
+	"random":         {},
+	"fixed":          {},
+	"min_avg10":      {},
+	"min_moving_avg": {},
+	"min":            {},
+}
+
+// Import splits a complete dae config into global, dns and routing configs, groups, nodes and subscriptions.
+// Parts that cannot be represented in dae-wing are skipped and reported in Unmapped. If rollbackError is true, nothing
+// is imported if any node or subscription fails to import; otherwise, they are skipped and reported in Unmapped too.
+func Import(ctx context.Context, name string, raw string, rollbackError bool) (r *Result, err error) {
+	if name == "" {
+		name = DefaultName
+	}
+	// Check grammar and to dae config.
+	parsedSections, err := config_parser.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	c, err := daeConfig.New(parsedSections)
+	if err != nil {
+		return nil, err
+	}
+	rawSections, err := dae.SplitSections(raw)
+	if err != nil {
+		return nil, err
+	}
+	r = &Result{}
+	sections := map[string]string{}
+	for _, s := range rawSections {
+		switch s.Name {
+		case "global", "dns", "routing":
+			if _, ok := sections[s.Name]; ok {
+				r.unmapped("section %v: duplicated section is ignored", s.Name)
+				continue
+			}
+			sections[s.Name] = s.Text
+		case "include":
+			r.unmapped("section include: included files are not imported")
+		}
+	}
+
+	if _, ok := sections["dns"]; !ok {
+		sections["dns"] = dae.EmptyDnsSection
+	}
+
+	// Fetch subscriptions before the transaction because it is slow.
+	type fetched struct {
+		arg   *internal.ImportArgument
+		label string
+		tag   string
+		links []string
+	}
+	var subscriptions []fetched
+	for i, s := range c.Subscription {
+		tag, link := daeCommon.GetTagFromLinkLikePlaintext(string(s))
+		arg := &internal.ImportArgument{Link: link}
+		label := fmt.Sprintf("#%v", i+1)
+		if tag != "" {
+			arg.Tag = &tag
+			label = tag
+		}
+		links, e := subscription.Fetch(arg)
+		if e != nil {
+			if rollbackError {
+				return nil, fmt.Errorf("subscription %v: %w", label, e)
+			}
+			r.unmapped("subscription %v: %v", label, e)
+			continue
+		}
+		subscriptions = append(subscriptions, fetched{arg: arg, label: label, tag: tag, links: links})
+	}
+
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	if r.Config, err = config.Insert(ctx, tx, name, sections["global"]); err != nil {
+		return nil, fmt.Errorf("global: %w", err)
+	}
+	if r.Dns, err = dns.Insert(ctx, tx, name, sections["dns"]); err != nil {
+		return nil, fmt.Errorf("dns: %w", err)
+	}
+	if r.Routing, err = routing.Insert(ctx, tx, name, sections["routing"]); err != nil {
+		return nil, fmt.Errorf("routing: %w", err)
+	}
+
+	// Node names and subscription tags referenced by group filters.
+	nameToNodes := map[string][]db.Node{}
+	tagToSubscription := map[string]*db.Subscription{}
+	var allNodes []db.Node
+	var allSubscriptions []db.Subscription
+
+	/// Subscriptions.
+	for i, s := range subscriptions {
+		// Leave no rows of a subscription that fails to import.
+		savePoint := fmt.Sprintf("subscription%v", i)
+		if err = tx.SavePoint(savePoint).Error; err != nil {
+			return nil, err
+		}
+		result, e := subscription.ImportLinks(tx, rollbackError, s.arg, s.links)
+		if e != nil {
+			if rollbackError {
+				return nil, fmt.Errorf("subscription %v: %w", s.label, e)
+			}
+			if err = tx.RollbackTo(savePoint).Error; err != nil {
+				return nil, err
+			}
+			r.unmapped("subscription %v: %v", s.label, e)
+			continue
+		}
+		r.Subscriptions = append(r.Subscriptions, result.Sub)
+		allSubscriptions = append(allSubscriptions, *result.Sub.Subscription)
+		if s.tag != "" {
+			tagToSubscription[s.tag] = result.Sub.Subscription
+		}
+		for _, nr := range result.NodeImportResult {
+			if nr.Node != nil {
+				nameToNodes[nr.Node.Node.Name] = append(nameToNodes[nr.Node.Node.Name], *nr.Node.Node)
+			}
+		}
+	}
+
+	/// Nodes.
+	var args []*internal.ImportArgument
+	for _, n := range c.Node {
+		tag, link := daeCommon.GetTagFromLinkLikePlaintext(string(n))
+		arg := &internal.ImportArgument{Link: link}
+		if tag != "" {
+			arg.Tag = &tag
+		}
+		args = append(args, arg)
+	}
+	if r.Nodes, err = node.Import(tx, rollbackError, nil, args); err != nil {
+		return nil, err
+	}
+	for i, nr := range r.Nodes {
+		var m *db.Node
+		if nr.Node != nil {
+			m = nr.Node.Node
+		}
+		if m == nil && nr.Error != nil && *nr.Error == node.DuplicatedError.Error() {
+			// Use the existing one.
+			var existing db.Node
+			if err = tx.Model(&db.Node{}).
+				Where("link = ?", args[i].Link).
+				Where("subscription_id is null").
+				First(&existing).Error; err != nil {
+				return nil, err
+			}
+			m = &existing
+		}
+		if m == nil {
+			continue
+		}
+		allNodes = append(allNodes, *m)
+		if m.Tag != nil {
+			nameToNodes[*m.Tag] = append(nameToNodes[*m.Tag], *m)
+		}
+		if m.Name != "" {
+			nameToNodes[m.Name] = append(nameToNodes[m.Name], *m)
+		}
+	}
+
+	/// Groups.
+	groupSections := map[string]*config_parser.Section{}
+	for _, s := range parsedSections {
+		if s.Name != "group" {
+			continue
+		}
+		for _, item := range s.Items {
+			if gs, ok := item.Value.(*config_parser.Section); ok {
+				groupSections[gs.Name] = gs
+			}
+		}
+	}
+	for _, g := range c.Group {
+		var rg *group.Resolver
+		if rg, err = importGroup(tx, r, &g, groupSections[g.Name], nameToNodes, tagToSubscription, allNodes, allSubscriptions); err != nil {
+			return nil, fmt.Errorf("group %v: %w", g.Name, err)
+		}
+		if rg != nil {
+			r.Groups = append(r.Groups, rg)
+		}
+	}
+	return r, nil
+}
+
+func importGroup(
+	d *gorm.DB,
+	r *Result,
+	g *daeConfig.Group,
+	section *config_parser.Section,
+	nameToNodes map[string][]db.Node,
+	tagToSubscription map[string]*db.Subscription,
+	allNodes []db.Node,
+	allSubscriptions []db.Subscription,
+) (*group.Resolver, error) {
+	// Policy.
+	fs := daeConfig.FunctionListOrStringToFunctionList(g.Policy)
+	if len(fs) != 1 || fs[0].Not {
+		r.unmapped("group %v: policy with more than one function is not supported; group is skipped", g.Name)
+		return nil, nil
+	}
+	if _, ok := policies[fs[0].Name]; !ok {
+		r.unmapped("group %v: policy %v is not supported; group is skipped", g.Name, fs[0].Name)
+		return nil, nil
+	}
+	var params []config_parser.Param
+	for _, p := range fs[0].Params {
+		params = append(params, config_parser.Param{Key: p.Key, Val: p.Val})
+	}
+	var count int64
+	if err := d.Model(&db.Group{}).Where("name = ?", g.Name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		r.unmapped("group %v: a group with the same name already exists; group is skipped", g.Name)
+		return nil, nil
+	}
+	rg, err := group.Insert(d, g.Name, fs[0].Name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Options dae-wing does not store.
+	if section != nil {
+		for _, item := range section.Items {
+			p, ok := item.Value.(*config_parser.Param)
+			if !ok {
+				continue
+			}
+			switch p.Key {
+			case "filter", "policy":
+			default:
+				r.unmapped("group %v: option %v is not supported and ignored", g.Name, p.Key)
+			}
+		}
+	}
+
+	// Filters.
+	var nodes []db.Node
+	var subs []db.Subscription
+	if len(g.Filter) == 0 {
+		// No filter means all nodes.
+		nodes = allNodes
+		subs = allSubscriptions
+	}
+	for _, filter := range g.Filter {
+		if len(filter) != 1 || filter[0].Not || (filter[0].Name != "name" && filter[0].Name != "subtag") {
+			var strFilter []string
+			for _, f := range filter {
+				strFilter = append(strFilter, f.String(false, true, false))
+			}
+			r.unmapped("group %v: filter %v is not supported", g.Name, strings.Join(strFilter, " && "))
+			continue
+		}
+		f := filter[0]
+		for _, p := range f.Params {
+			if p.Key != "" {
+				r.unmapped("group %v: filter %v(%v) is not supported", g.Name, f.Name, p.String(false, true))
+				continue
+			}
+			switch f.Name {
+			case "name":
+				matched, ok := nameToNodes[p.Val]
+				if !ok {
+					r.unmapped("group %v: node %v is not found", g.Name, p.Val)
+					continue
+				}
+				nodes = append(nodes, matched...)
+			case "subtag":
+				sub, ok := tagToSubscription[p.Val]
+				if !ok {
+					r.unmapped("group %v: subscription %v is not found", g.Name, p.Val)
+					continue
+				}
+				subs = append(subs, *sub)
+			}
+		}
+	}
+	if len(nodes) > 0 {
+		if err = d.Model(rg.Group).Association("Node").Append(nodes); err != nil {
+			return nil, err
+		}
+	}
+	if len(subs) > 0 {
+		if err = d.Model(rg.Group).Association("Subscription").Append(subs); err != nil {
+			return nil, err
+		}
+	}
+	if len(nodes) == 0 && len(subs) == 0 {
+		r.unmapped("group %v: no node or subscription is added", g.Name)
+	}
+	return rg, nil
+}
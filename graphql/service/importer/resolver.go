@@ -0,0 +1,31 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package importer
+
+import (
+	"fmt"
+
+	"github.com/daeuniverse/dae-wing/graphql/service/config"
+	"github.com/daeuniverse/dae-wing/graphql/service/dns"
+	"github.com/daeuniverse/dae-wing/graphql/service/group"
+	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae-wing/graphql/service/routing"
+	"github.com/daeuniverse/dae-wing/graphql/service/subscription"
+)
+
+type Result struct {
+	Config        *config.Resolver
+	Dns           *dns.Resolver
+	Routing       *routing.Resolver
+	Groups        []*group.Resolver
+	Nodes         []*node.ImportResult
+	Subscriptions []*subscription.Resolver
+	Unmapped      []string
+}
+
+func (r *Result) unmapped(format string, args ...interface{}) {
+	r.Unmapped = append(r.Unmapped, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,21 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package importer
+
+func Schema() (string, error) {
+	return `
+type DaeConfigImportResult {
+	config: Config!
+	dns: Dns!
+	routing: Routing!
+	groups: [Group!]!
+	nodes: [NodeImportResult!]!
+	subscriptions: [Subscription!]!
+	# unmapped describes parts of the dae config that were not imported, such as group filters other than name and subtag.
+	unmapped: [String!]!
+}
+`, nil
+}
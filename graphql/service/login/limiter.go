@@ -0,0 +1,223 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package login
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// FreeFailures is the number of failures allowed before backoff starts.
+	FreeFailures = 3
+	// MaxFailures is the number of failures that triggers a lockout.
+	MaxFailures = 10
+	// BaseDelay is the first backoff delay, which doubles on every further failure.
+	BaseDelay = time.Second
+	// MaxDelay caps the backoff delay.
+	MaxDelay = time.Minute
+	// LockoutDuration is how long a lockout lasts.
+	LockoutDuration = 15 * time.Minute
+	// Window is the period after which failures are forgotten.
+	Window = 15 * time.Minute
+)
+
+const (
+	KindIp       = "ip"
+	KindUsername = "username"
+)
+
+type ErrThrottled struct {
+	RetryAfter time.Duration
+}
+
+func (e *ErrThrottled) Error() string {
+	return fmt.Sprintf("too many failed login attempts; retry after %v", time.Duration(math.Ceil(e.RetryAfter.Seconds()))*time.Second)
+}
+
+type key struct {
+	Kind  string
+	Value string
+}
+
+type entry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	// pending is the number of attempts allowed but not finished yet.
+	pending int
+}
+
+// Lockout describes a client IP or username that is not allowed to log in for now.
+type Lockout struct {
+	Kind         string
+	Key          string
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+}
+
+// Limiter throttles login attempts per client IP and per username with exponential backoff.
+type Limiter struct {
+	mu      sync.Mutex
+	entries map[key]*entry
+	// now is the clock, which tests replace.
+	now func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{entries: map[key]*entry{}, now: time.Now}
+}
+
+var DefaultLimiter = NewLimiter()
+
+func keys(clientIp string, username string) []key {
+	return []key{{Kind: KindIp, Value: clientIp}, {Kind: KindUsername, Value: username}}
+}
+
+// Allow returns ErrThrottled if either the client IP or the username is blocked. Otherwise, it reserves an attempt,
+// which must be finished by Fail, Succeed or Release. Attempts in progress count as failures so that concurrent
+// attempts cannot get around the backoff; once they are finished, only the backoff of recorded failures applies.
+func (l *Limiter) Allow(clientIp string, username string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.gc(now)
+	var retryAfter time.Duration
+	for _, k := range keys(clientIp, username) {
+		e, ok := l.entries[k]
+		if !ok {
+			continue
+		}
+		if d := e.blockedUntil.Sub(now); d > retryAfter {
+			retryAfter = d
+		}
+		failures := e.failures
+		if now.Sub(e.lastFailure) > Window {
+			failures = 0
+		}
+		if e.pending > 0 && failures+e.pending >= FreeFailures && retryAfter < BaseDelay {
+			// Wait for attempts in progress to decide the backoff.
+			retryAfter = BaseDelay
+		}
+	}
+	if retryAfter > 0 {
+		return &ErrThrottled{RetryAfter: retryAfter}
+	}
+	for _, k := range keys(clientIp, username) {
+		e, ok := l.entries[k]
+		if !ok {
+			e = &entry{}
+			l.entries[k] = e
+		}
+		e.pending++
+	}
+	return nil
+}
+
+// release finishes an attempt reserved by Allow.
+func (e *entry) release() {
+	if e.pending > 0 {
+		e.pending--
+	}
+}
+
+// Fail finishes the attempt as failed and blocks further attempts for a while.
+func (l *Limiter) Fail(clientIp string, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for _, k := range keys(clientIp, username) {
+		e, ok := l.entries[k]
+		if !ok {
+			e = &entry{}
+			l.entries[k] = e
+		}
+		e.release()
+		if now.Sub(e.lastFailure) > Window {
+			e.failures = 0
+		}
+		e.failures++
+		e.lastFailure = now
+		switch {
+		case e.failures >= MaxFailures:
+			e.blockedUntil = now.Add(LockoutDuration)
+		case e.failures >= FreeFailures:
+			delay := BaseDelay << (e.failures - FreeFailures)
+			if delay > MaxDelay {
+				delay = MaxDelay
+			}
+			e.blockedUntil = now.Add(delay)
+		}
+	}
+}
+
+// Succeed finishes the attempt and forgets failures of the username. Failures of the client IP are kept so that
+// a valid account cannot be used to reset the counter while guessing others.
+func (l *Limiter) Succeed(clientIp string, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key{Kind: KindIp, Value: clientIp}]; ok {
+		e.release()
+	}
+	delete(l.entries, key{Kind: KindUsername, Value: username})
+	l.gc(l.now())
+}
+
+// Release finishes the attempt without counting it.
+func (l *Limiter) Release(clientIp string, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys(clientIp, username) {
+		if e, ok := l.entries[k]; ok {
+			e.release()
+		}
+	}
+	l.gc(l.now())
+}
+
+// Unlock removes the lockout of given kind and key.
+func (l *Limiter) Unlock(kind string, k string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.entries[key{Kind: kind, Value: k}]
+	delete(l.entries, key{Kind: kind, Value: k})
+	return ok
+}
+
+// Lockouts returns entries that are blocked now.
+func (l *Limiter) Lockouts() (lockouts []Lockout) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for k, e := range l.entries {
+		if !e.blockedUntil.After(now) {
+			continue
+		}
+		lockouts = append(lockouts, Lockout{
+			Kind:         k.Kind,
+			Key:          k.Value,
+			Failures:     e.failures,
+			LastFailure:  e.lastFailure,
+			BlockedUntil: e.blockedUntil,
+		})
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].BlockedUntil.After(lockouts[j].BlockedUntil)
+	})
+	return lockouts
+}
+
+func (l *Limiter) gc(now time.Time) {
+	for k, e := range l.entries {
+		if e.pending == 0 && now.Sub(e.lastFailure) > Window && now.After(e.blockedUntil) {
+			delete(l.entries, k)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package login
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterBackoff(t *testing.T) {
+	l := NewLimiter()
+	for i := 0; i < FreeFailures; i++ {
+		if err := l.Allow("1.1.1.1", "alice"); err != nil {
+			t.Fatalf("attempt %v: %v", i+1, err)
+		}
+		l.Fail("1.1.1.1", "alice")
+	}
+	var throttled *ErrThrottled
+	if err := l.Allow("1.1.1.1", "bob"); !errors.As(err, &throttled) {
+		t.Fatalf("expected the ip to be throttled, got %v", err)
+	}
+	if err := l.Allow("2.2.2.2", "alice"); !errors.As(err, &throttled) {
+		t.Fatalf("expected the username to be throttled, got %v", err)
+	}
+	if err := l.Allow("2.2.2.2", "bob"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimiterConcurrentAttempts(t *testing.T) {
+	l := NewLimiter()
+	// Attempts in progress are reserved, so no more than FreeFailures attempts can be in progress before any fails.
+	for i := 0; i < FreeFailures; i++ {
+		if err := l.Allow("1.1.1.1", "alice"); err != nil {
+			t.Fatalf("attempt %v: %v", i+1, err)
+		}
+	}
+	if err := l.Allow("1.1.1.1", "alice"); err == nil {
+		t.Fatal("expected concurrent attempts beyond free failures to be throttled")
+	}
+	for i := 0; i < FreeFailures; i++ {
+		l.Release("1.1.1.1", "alice")
+	}
+	if err := l.Allow("1.1.1.1", "alice"); err != nil {
+		t.Fatalf("released attempts should not count: %v", err)
+	}
+	l.Succeed("1.1.1.1", "alice")
+	if len(l.entries) != 0 {
+		t.Fatalf("expected no entries left, got %v", len(l.entries))
+	}
+}
+
+func TestLimiterBackoffExpires(t *testing.T) {
+	l := NewLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	for i := 0; i < FreeFailures; i++ {
+		if err := l.Allow("1.1.1.1", "alice"); err != nil {
+			t.Fatalf("attempt %v: %v", i+1, err)
+		}
+		l.Fail("1.1.1.1", "alice")
+	}
+	// Failures beyond free ones double the delay until the lockout.
+	for failures := FreeFailures; failures < MaxFailures; failures++ {
+		delay := BaseDelay << (failures - FreeFailures)
+		if err := l.Allow("1.1.1.1", "alice"); err == nil {
+			t.Fatalf("expected a backoff after %v failures", failures)
+		}
+		now = now.Add(delay + time.Millisecond)
+		if err := l.Allow("1.1.1.1", "alice"); err != nil {
+			t.Fatalf("expected the backoff after %v failures to expire: %v", failures, err)
+		}
+		if len(l.Lockouts()) != 0 {
+			t.Fatalf("unexpected lockouts after %v failures: %v", failures, l.Lockouts())
+		}
+		l.Fail("1.1.1.1", "alice")
+	}
+	if lockouts := l.Lockouts(); len(lockouts) != 2 || !lockouts[0].BlockedUntil.Equal(now.Add(LockoutDuration)) {
+		t.Fatalf("expected the ip and the username to be locked out, got %v", lockouts)
+	}
+	now = now.Add(LockoutDuration + time.Millisecond)
+	if err := l.Allow("1.1.1.1", "alice"); err != nil {
+		t.Fatalf("expected the lockout to expire: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package login
+
+import (
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"gorm.io/gorm"
+)
+
+// Retention is how long login attempts are kept.
+const Retention = 90 * 24 * time.Hour
+
+const (
+	ReasonIncorrectCredentials  = "incorrect_credentials"
+	ReasonIncorrectSecondFactor = "incorrect_second_factor"
+	ReasonThrottled             = "throttled"
+)
+
+// Record saves a login attempt.
+func Record(d *gorm.DB, username string, clientIp string, userAgent string, success bool, reason string) error {
+	now := time.Now()
+	// Clean up outdated records by the way.
+	if err := d.Where("created_at < ?", now.Add(-Retention)).
+		Delete(&db.LoginAttempt{}).Error; err != nil {
+		return err
+	}
+	return d.Create(&db.LoginAttempt{
+		Username:  username,
+		ClientIp:  clientIp,
+		UserAgent: userAgent,
+		Success:   success,
+		Reason:    reason,
+		CreatedAt: now,
+	}).Error
+}
@@ -0,0 +1,73 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package login
+
+import (
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type AttemptResolver struct {
+	*db.LoginAttempt
+}
+
+func (r *AttemptResolver) ID() graphql.ID {
+	return common.EncodeCursor(r.LoginAttempt.ID)
+}
+
+func (r *AttemptResolver) Username() string {
+	return r.LoginAttempt.Username
+}
+
+func (r *AttemptResolver) ClientIp() string {
+	return r.LoginAttempt.ClientIp
+}
+
+func (r *AttemptResolver) UserAgent() string {
+	return r.LoginAttempt.UserAgent
+}
+
+func (r *AttemptResolver) Success() bool {
+	return r.LoginAttempt.Success
+}
+
+func (r *AttemptResolver) Reason() *string {
+	if r.LoginAttempt.Reason == "" {
+		return nil
+	}
+	return &r.LoginAttempt.Reason
+}
+
+func (r *AttemptResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.LoginAttempt.CreatedAt}
+}
+
+type LockoutResolver struct {
+	Lockout
+}
+
+func (r *LockoutResolver) Kind() string {
+	return strings.ToUpper(r.Lockout.Kind)
+}
+
+func (r *LockoutResolver) Key() string {
+	return r.Lockout.Key
+}
+
+func (r *LockoutResolver) Failures() int32 {
+	return int32(r.Lockout.Failures)
+}
+
+func (r *LockoutResolver) LastFailure() graphql.Time {
+	return graphql.Time{Time: r.Lockout.LastFailure}
+}
+
+func (r *LockoutResolver) BlockedUntil() graphql.Time {
+	return graphql.Time{Time: r.Lockout.BlockedUntil}
+}
@@ -0,0 +1,33 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package login
+
+func Schema() (string, error) {
+	return `
+type LoginAttempt {
+	id: ID!
+	username: String!
+	clientIp: String!
+	userAgent: String!
+	success: Boolean!
+	# reason is why the attempt failed. It is one of "incorrect_credentials", "incorrect_second_factor" and "throttled".
+	reason: String
+	createdAt: Time!
+}
+enum LoginLockoutKind {
+	IP
+	USERNAME
+}
+type LoginLockout {
+	kind: LoginLockoutKind!
+	# key is the client IP or the username.
+	key: String!
+	failures: Int!
+	lastFailure: Time!
+	blockedUntil: Time!
+}
+`, nil
+}
@@ -0,0 +1,152 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package logs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/graphql/service/event"
+	"github.com/sirupsen/logrus"
+)
+
+// Capacity is how many latest entries are kept in memory.
+const Capacity = 2000
+
+const DefaultLimit = 100
+
+// Entry is a log entry kept in memory.
+type Entry struct {
+	Time    time.Time
+	Level   logrus.Level
+	Message string
+	Fields  []*FieldResolver
+}
+
+// Filter selects entries at MinLevel or more severe whose message or fields contain Text.
+type Filter struct {
+	MinLevel logrus.Level
+	Text     string
+}
+
+func (f *Filter) match(e *Entry) bool {
+	if e.Level > f.MinLevel {
+		return false
+	}
+	if f.Text == "" || strings.Contains(e.Message, f.Text) {
+		return true
+	}
+	for _, field := range e.Fields {
+		if strings.Contains(field.Value, f.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hook keeps logs in a ring buffer and streams them to subscribers.
+var Hook = &hook{}
+
+type hook struct {
+	mu      sync.RWMutex
+	entries [Capacity]*Entry
+	next    int
+	full    bool
+	stream  event.Broker[*Entry]
+}
+
+func (h *hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *hook) Fire(entry *logrus.Entry) error {
+	e := &Entry{
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Message: entry.Message,
+	}
+	for k, v := range entry.Data {
+		e.Fields = append(e.Fields, &FieldResolver{Key: k, Value: fmt.Sprint(v)})
+	}
+	sort.Slice(e.Fields, func(i, j int) bool {
+		return e.Fields[i].Key < e.Fields[j].Key
+	})
+	h.mu.Lock()
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % Capacity
+	if h.next == 0 {
+		h.full = true
+	}
+	h.mu.Unlock()
+	h.stream.Publish(e)
+	return nil
+}
+
+// Query returns at most limit latest entries after since that match the filter, from the oldest to the newest.
+func Query(since *time.Time, filter *Filter, limit int) []*Entry {
+	h := Hook
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	n := h.next
+	if h.full {
+		n = Capacity
+	}
+	var entries []*Entry
+	// Walk from the newest to the oldest.
+	for i := 0; i < n && len(entries) < limit; i++ {
+		e := h.entries[(h.next-1-i+Capacity)%Capacity]
+		if since != nil && !e.Time.After(*since) {
+			break
+		}
+		if filter.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
+
+// Stream returns a channel of new entries that match the filter until ctx is done.
+func Stream(ctx context.Context, filter *Filter) <-chan *Entry {
+	all := Hook.stream.Subscribe(ctx)
+	ch := make(chan *Entry)
+	go func() {
+		defer close(ch)
+		for e := range all {
+			if !filter.match(e) {
+				continue
+			}
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// ParseLevel parses a LogLevel of the schema. Empty is TRACE, which matches all entries.
+func ParseLevel(level string) (logrus.Level, error) {
+	if level == "" {
+		return logrus.TraceLevel, nil
+	}
+	return logrus.ParseLevel(strings.ToLower(level))
+}
+
+// LevelName returns the LogLevel of the schema.
+func LevelName(level logrus.Level) string {
+	if level == logrus.WarnLevel {
+		return "WARN"
+	}
+	return strings.ToUpper(level.String())
+}
@@ -0,0 +1,35 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package logs
+
+import (
+	"github.com/graph-gophers/graphql-go"
+)
+
+type Resolver struct {
+	*Entry
+}
+
+func (r *Resolver) Time() graphql.Time {
+	return graphql.Time{Time: r.Entry.Time}
+}
+
+func (r *Resolver) Level() string {
+	return LevelName(r.Entry.Level)
+}
+
+func (r *Resolver) Message() string {
+	return r.Entry.Message
+}
+
+func (r *Resolver) Fields() []*FieldResolver {
+	return r.Entry.Fields
+}
+
+type FieldResolver struct {
+	Key   string
+	Value string
+}
@@ -0,0 +1,30 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package logs
+
+func Schema() (string, error) {
+	return `
+enum LogLevel {
+	TRACE
+	DEBUG
+	INFO
+	WARN
+	ERROR
+	FATAL
+	PANIC
+}
+type LogEntry {
+	time: Time!
+	level: LogLevel!
+	message: String!
+	fields: [LogField!]!
+}
+type LogField {
+	key: String!
+	value: String!
+}
+`, nil
+}
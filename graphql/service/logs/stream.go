@@ -0,0 +1,90 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+type sseEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// SseHandler streams logs as server-sent events of JSON entries to admins. Query parameters "level" and "text" filter
+// entries the same as the logs query.
+func SseHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role, ok := r.Context().Value("role").(string); !ok || !db.RoleSatisfies(role, db.RoleAdmin) {
+			http.Error(w, `access denied, "ADMIN" role required`, http.StatusForbidden)
+			return
+		}
+		level, err := ParseLevel(r.URL.Query().Get("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		// The context of requests is detached from the connection by the auth middleware, so heartbeats are written to
+		// find out closed connections.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		entries := Stream(ctx, &Filter{MinLevel: level, Text: r.URL.Query().Get("text")})
+		for {
+			var e *Entry
+			select {
+			case <-heartbeat.C:
+				if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+				continue
+			case e = <-entries:
+			}
+			se := sseEntry{
+				Time:    e.Time,
+				Level:   LevelName(e.Level),
+				Message: e.Message,
+			}
+			if len(e.Fields) > 0 {
+				se.Fields = map[string]string{}
+				for _, f := range e.Fields {
+					se.Fields[f.Key] = f.Value
+				}
+			}
+			b, err := json.Marshal(se)
+			if err != nil {
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -7,6 +7,8 @@ package node
 
 import (
 	"context"
+	"fmt"
+
 	"github.com/daeuniverse/dae-wing/common"
 	"github.com/daeuniverse/dae-wing/db"
 	"github.com/daeuniverse/dae-wing/graphql/service"
@@ -16,11 +18,34 @@ import (
 
 type ConnectionResolver struct {
 	baseQuery func() *gorm.DB
+	// after filters nodes ordered after the node with the id.
+	after func(q *gorm.DB, id uint) *gorm.DB
 
 	models []db.Node
 }
 
-func NewConnectionResolver(_id *graphql.ID, _subscriptionId *graphql.ID, first *int32, _after *graphql.ID) (r *ConnectionResolver, err error) {
+const (
+	OrderById            = "ID"
+	OrderByName          = "NAME"
+	OrderByLatency       = "LATENCY"
+	OrderByLastCheckedAt = "LAST_CHECKED_AT"
+	OrderByUptime        = "UPTIME"
+	OrderByLatencyP50    = "LATENCY_P50"
+	OrderByLatencyP95    = "LATENCY_P95"
+)
+
+// orderKeys are sql expressions to order nodes by. Nulls are replaced to keep keyset pagination simple.
+var orderKeys = map[string]string{
+	OrderById:            "id",
+	OrderByName:          "name",
+	OrderByLatency:       "coalesce(latency, 9223372036854775807)",
+	OrderByLastCheckedAt: "coalesce(last_checked_at, '')",
+	OrderByUptime:        "coalesce((select uptime from node_health_stats where node_health_stats.link = nodes.link), -1)",
+	OrderByLatencyP50:    "coalesce((select latency_p50 from node_health_stats where node_health_stats.link = nodes.link), 9223372036854775807)",
+	OrderByLatencyP95:    "coalesce((select latency_p95 from node_health_stats where node_health_stats.link = nodes.link), 9223372036854775807)",
+}
+
+func NewConnectionResolver(_id *graphql.ID, _subscriptionId *graphql.ID, first *int32, _after *graphql.ID, orderBy *string, desc *bool) (r *ConnectionResolver, err error) {
 	var id uint
 	var subscriptionId uint
 	if _id != nil {
@@ -35,6 +60,17 @@ func NewConnectionResolver(_id *graphql.ID, _subscriptionId *graphql.ID, first *
 			return nil, err
 		}
 	}
+	key := orderKeys[OrderById]
+	if orderBy != nil {
+		var ok bool
+		if key, ok = orderKeys[*orderBy]; !ok {
+			return nil, fmt.Errorf("unknown order: %v", *orderBy)
+		}
+	}
+	op, direction := ">", "ASC"
+	if desc != nil && *desc {
+		op, direction = "<", "DESC"
+	}
 	baseQuery := func() *gorm.DB {
 		q := db.DB(context.TODO()).Model(&db.Node{})
 		if _id != nil {
@@ -47,14 +83,21 @@ func NewConnectionResolver(_id *graphql.ID, _subscriptionId *graphql.ID, first *
 		}
 		return q
 	}
+	after := func(q *gorm.DB, id uint) *gorm.DB {
+		if key == "id" {
+			return q.Where(fmt.Sprintf("id %v ?", op), id)
+		}
+		afterKey := fmt.Sprintf("(select %v from nodes where id = ?)", key)
+		return q.Where(fmt.Sprintf("%v %v %v or (%v = %v and id %v ?)", key, op, afterKey, key, afterKey, op), id, id, id)
+	}
 
-	q := baseQuery()
+	q := baseQuery().Order(fmt.Sprintf("%v %v, id %v", key, direction, direction))
 	if _after != nil {
-		after, err := common.DecodeCursor(*_after)
+		afterId, err := common.DecodeCursor(*_after)
 		if err != nil {
 			return nil, err
 		}
-		q = q.Where("id > ?", after)
+		q = after(q, afterId)
 	}
 	if first != nil {
 		q = q.Limit(int(*first))
@@ -65,6 +108,7 @@ func NewConnectionResolver(_id *graphql.ID, _subscriptionId *graphql.ID, first *
 	}
 	return &ConnectionResolver{
 		baseQuery: baseQuery,
+		after:     after,
 		models:    models,
 	}, nil
 }
@@ -95,14 +139,14 @@ func (r *ConnectionResolver) PageInfo() (pr *service.PageInfoResolver, err error
 	}
 	start := common.EncodeCursor(r.models[0].ID)
 	end := common.EncodeCursor(r.models[len(r.models)-1].ID)
-	// Get the last ID.
-	var lastNode db.Node
-	if err := r.baseQuery().Select("id").Order("id DESC").First(&lastNode).Error; err != nil {
+	// Check if any node is ordered after the last one.
+	var count int64
+	if err := r.after(r.baseQuery(), r.models[len(r.models)-1].ID).Limit(1).Count(&count).Error; err != nil {
 		return nil, err
 	}
 	return &service.PageInfoResolver{
 		FStartCursor: &start,
 		FEndCursor:   &end,
-		FHasNextPage: r.models[len(r.models)-1].ID < lastNode.ID,
+		FHasNextPage: count > 0,
 	}, nil
 }
@@ -0,0 +1,263 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package node
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/go-co-op/gocron"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// HealthRetention is how long health samples are kept.
+	HealthRetention = 30 * 24 * time.Hour
+	// HealthStatsWindow is the period that uptime and latency percentiles are computed over.
+	HealthStatsWindow = 7 * 24 * time.Hour
+	// Samples older than healthRawRetention are merged by healthBucket.
+	healthRawRetention = 24 * time.Hour
+	healthBucket       = time.Hour
+)
+
+// StartHealthCheck checks all nodes every interval and records the results.
+func StartHealthCheck(interval time.Duration) error {
+	s := gocron.NewScheduler(time.Local)
+	_, err := s.Every(interval).WaitForSchedule().SingletonMode().Do(func() {
+		if err := CheckHealth(context.Background()); err != nil {
+			logrus.Warnln("Failed to check health of nodes:", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	s.StartAsync()
+	return nil
+}
+
+// CheckHealth tests all nodes with the default test url, and then merges and removes old samples.
+func CheckHealth(ctx context.Context) (err error) {
+	var ids []uint
+	if err = db.DB(ctx).Model(&db.Node{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		u, _ := url.Parse(DefaultTestUrl)
+		if _, err = test(ctx, ids, u, DefaultTestTimeout); err != nil {
+			return err
+		}
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	return compactHealth(tx, time.Now())
+}
+
+// healthTime is the time to save in samples. Times are saved in UTC and seconds so that they compare as strings.
+func healthTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// recordHealth saves results of a test as samples and updates the stats of tested links.
+func recordHealth(d *gorm.DB, rs []*TestResult, at time.Time) error {
+	at = healthTime(at)
+	samples := make([]db.NodeHealthSample, 0, len(rs))
+	links := make([]string, 0, len(rs))
+	for _, r := range rs {
+		s := db.NodeHealthSample{
+			Link:   r.node.Link,
+			At:     at,
+			Checks: 1,
+		}
+		if r.err == nil {
+			s.Successes = 1
+			s.Latency = r.latency
+		}
+		samples = append(samples, s)
+		links = append(links, r.node.Link)
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	if err := d.Create(&samples).Error; err != nil {
+		return err
+	}
+	return updateHealthStats(d, links, at)
+}
+
+// mergeSample adds checks of s to dst.
+func mergeSample(dst *db.NodeHealthSample, s *db.NodeHealthSample) {
+	if s.Latency != nil {
+		var sum time.Duration
+		if dst.Latency != nil {
+			sum = *dst.Latency * time.Duration(dst.Successes)
+		}
+		latency := (sum + *s.Latency*time.Duration(s.Successes)) / time.Duration(dst.Successes+s.Successes)
+		dst.Latency = &latency
+	}
+	dst.Checks += s.Checks
+	dst.Successes += s.Successes
+}
+
+// compactHealth removes expired samples and samples of removed nodes, and merges old samples by hour.
+func compactHealth(d *gorm.DB, now time.Time) error {
+	now = healthTime(now)
+	if err := d.Where("at < ?", now.Add(-HealthRetention)).Delete(&db.NodeHealthSample{}).Error; err != nil {
+		return err
+	}
+	if err := d.Where("link not in (?)", d.Model(&db.Node{}).Select("link")).Delete(&db.NodeHealthSample{}).Error; err != nil {
+		return err
+	}
+	if err := d.Where("link not in (?)", d.Model(&db.Node{}).Select("link")).Delete(&db.NodeHealthStat{}).Error; err != nil {
+		return err
+	}
+
+	var raw []db.NodeHealthSample
+	if err := d.Where("merged = ? and at < ?", false, now.Add(-healthRawRetention)).Order("id").Find(&raw).Error; err != nil {
+		return err
+	}
+	type bucketKey struct {
+		link string
+		at   time.Time
+	}
+	buckets := make(map[bucketKey]*db.NodeHealthSample)
+	var keys []bucketKey
+	ids := make([]uint, 0, len(raw))
+	for i := range raw {
+		s := &raw[i]
+		ids = append(ids, s.ID)
+		k := bucketKey{link: s.Link, at: s.At.UTC().Truncate(healthBucket)}
+		b, ok := buckets[k]
+		if !ok {
+			b = &db.NodeHealthSample{Link: k.link, At: k.at, Merged: true}
+			buckets[k] = b
+			keys = append(keys, k)
+		}
+		mergeSample(b, s)
+	}
+	for _, k := range keys {
+		b := buckets[k]
+		// The bucket may have been merged partly by the last compaction.
+		var existing db.NodeHealthSample
+		q := d.Where("link = ? and at = ? and merged = ?", k.link, k.at, true).Limit(1).Find(&existing)
+		if q.Error != nil {
+			return q.Error
+		}
+		if q.RowsAffected > 0 {
+			mergeSample(&existing, b)
+			b = &existing
+		}
+		if err := d.Save(b).Error; err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		if err := d.Where("id in ?", ids).Delete(&db.NodeHealthSample{}).Error; err != nil {
+			return err
+		}
+	}
+
+	// The stats window moves.
+	var links []string
+	if err := d.Model(&db.NodeHealthStat{}).Pluck("link", &links).Error; err != nil {
+		return err
+	}
+	return updateHealthStats(d, links, now)
+}
+
+// updateHealthStats computes stats of links from samples in HealthStatsWindow before now.
+func updateHealthStats(d *gorm.DB, links []string, now time.Time) error {
+	if len(links) == 0 {
+		return nil
+	}
+	var samples []db.NodeHealthSample
+	if err := d.Where("link in ? and at >= ?", links, healthTime(now.Add(-HealthStatsWindow))).
+		Find(&samples).Error; err != nil {
+		return err
+	}
+	linkToSamples := make(map[string][]db.NodeHealthSample)
+	for _, s := range samples {
+		linkToSamples[s.Link] = append(linkToSamples[s.Link], s)
+	}
+	var stats []db.NodeHealthStat
+	var stale []string
+	seen := make(map[string]struct{}, len(links))
+	for _, link := range links {
+		if _, ok := seen[link]; ok {
+			continue
+		}
+		seen[link] = struct{}{}
+		if ss, ok := linkToSamples[link]; ok {
+			stats = append(stats, computeHealthStat(link, ss))
+		} else {
+			stale = append(stale, link)
+		}
+	}
+	if len(stale) > 0 {
+		if err := d.Where("link in ?", stale).Delete(&db.NodeHealthStat{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	return d.Clauses(clause.OnConflict{UpdateAll: true}).Create(&stats).Error
+}
+
+func computeHealthStat(link string, samples []db.NodeHealthSample) db.NodeHealthStat {
+	stat := db.NodeHealthStat{Link: link}
+	var checks, successes uint
+	for _, s := range samples {
+		checks += s.Checks
+		successes += s.Successes
+	}
+	if checks > 0 {
+		stat.Uptime = float64(successes) / float64(checks) * 100
+	}
+	// Latencies of merged samples are averages and weighted by their successes.
+	var weighted []db.NodeHealthSample
+	var total uint
+	for _, s := range samples {
+		if s.Latency != nil && s.Successes > 0 {
+			weighted = append(weighted, s)
+			total += s.Successes
+		}
+	}
+	sort.Slice(weighted, func(i, j int) bool {
+		return *weighted[i].Latency < *weighted[j].Latency
+	})
+	stat.LatencyP50 = percentile(weighted, total, 0.5)
+	stat.LatencyP95 = percentile(weighted, total, 0.95)
+	return stat
+}
+
+// percentile returns the latency at the nearest rank of sorted samples whose successes sum to total.
+func percentile(sorted []db.NodeHealthSample, total uint, p float64) *time.Duration {
+	if total == 0 {
+		return nil
+	}
+	rank := uint(math.Ceil(p * float64(total)))
+	var cumulative uint
+	for _, s := range sorted {
+		cumulative += s.Successes
+		if cumulative >= rank {
+			latency := *s.Latency
+			return &latency
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+func ms(n int) *time.Duration {
+	d := time.Duration(n) * time.Millisecond
+	return &d
+}
+
+func sample(checks, successes uint, latency *time.Duration) db.NodeHealthSample {
+	return db.NodeHealthSample{Checks: checks, Successes: successes, Latency: latency}
+}
+
+func equalLatency(a, b *time.Duration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestMergeSample(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  db.NodeHealthSample
+		s    db.NodeHealthSample
+		want db.NodeHealthSample
+	}{
+		{name: "average", dst: sample(1, 1, ms(100)), s: sample(1, 1, ms(200)), want: sample(2, 2, ms(150))},
+		{name: "weighted by successes", dst: sample(2, 2, ms(100)), s: sample(1, 1, ms(400)), want: sample(3, 3, ms(200))},
+		{name: "into failures", dst: sample(2, 0, nil), s: sample(1, 1, ms(50)), want: sample(3, 1, ms(50))},
+		{name: "a failure", dst: sample(3, 3, ms(100)), s: sample(1, 0, nil), want: sample(4, 3, ms(100))},
+		{name: "failures", dst: sample(1, 0, nil), s: sample(1, 0, nil), want: sample(2, 0, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.dst
+			mergeSample(&got, &tt.s)
+			if got.Checks != tt.want.Checks || got.Successes != tt.want.Successes || !equalLatency(got.Latency, tt.want.Latency) {
+				t.Fatalf("expected %v/%v %v, got %v/%v %v", tt.want.Successes, tt.want.Checks, tt.want.Latency,
+					got.Successes, got.Checks, got.Latency)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []db.NodeHealthSample{sample(1, 1, ms(10)), sample(3, 3, ms(20)), sample(2, 1, ms(30))}
+	tests := []struct {
+		name   string
+		sorted []db.NodeHealthSample
+		total  uint
+		p      float64
+		want   *time.Duration
+	}{
+		{name: "lowest rank", sorted: sorted, total: 5, p: 0.2, want: ms(10)},
+		{name: "median spans a merged sample", sorted: sorted, total: 5, p: 0.5, want: ms(20)},
+		{name: "p95", sorted: sorted, total: 5, p: 0.95, want: ms(30)},
+		{name: "single sample", sorted: sorted[:1], total: 1, p: 0.95, want: ms(10)},
+		{name: "no successes", total: 0, p: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.total, tt.p); !equalLatency(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestComputeHealthStat(t *testing.T) {
+	stat := computeHealthStat("link", []db.NodeHealthSample{
+		sample(1, 1, ms(30)),
+		sample(1, 0, nil),
+		sample(3, 3, ms(10)),
+	})
+	if stat.Uptime != 80 {
+		t.Fatalf("expected uptime 80, got %v", stat.Uptime)
+	}
+	if !equalLatency(stat.LatencyP50, ms(10)) || !equalLatency(stat.LatencyP95, ms(30)) {
+		t.Fatalf("unexpected percentiles: %v %v", stat.LatencyP50, stat.LatencyP95)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/dae"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/outbound/netproxy"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultTestUrl     = "http://cp.cloudflare.com"
+	DefaultTestTimeout = 5 * time.Second
+	MaxTestTimeout     = time.Minute
+)
+
+// testConcurrency limits nodes tested at the same time.
+const testConcurrency = 16
+
+type TestResult struct {
+	node    *db.Node
+	latency *time.Duration
+	err     *string
+}
+
+// ParseTestUrl parses the url to test nodes with. Schemes http and https send a GET request, and tcp only connects to
+// the host and port through the node.
+func ParseTestUrl(rawUrl string) (*url.URL, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("bad test url: %v", rawUrl)
+		}
+	case "tcp":
+		if _, _, err = net.SplitHostPort(u.Host); err != nil {
+			return nil, fmt.Errorf("bad test url: %v: %w", rawUrl, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scheme of test url: %v", rawUrl)
+	}
+	return u, nil
+}
+
+// Test checks connectivity through nodes concurrently and saves the latency and the time of the check. Nodes that
+// are not found are skipped.
+func Test(ctx context.Context, _ids []graphql.ID, u *url.URL, timeout time.Duration) (rs []*TestResult, err error) {
+	ids, err := common.DecodeCursorBatch(_ids)
+	if err != nil {
+		return nil, err
+	}
+	return test(ctx, ids, u, timeout)
+}
+
+func test(ctx context.Context, ids []uint, u *url.URL, timeout time.Duration) (rs []*TestResult, err error) {
+	var models []db.Node
+	if err = db.DB(ctx).Model(&db.Node{}).Where("id in ?", ids).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	idToNode := make(map[uint]*db.Node, len(models))
+	for i := range models {
+		idToNode[models[i].ID] = &models[i]
+	}
+	rs = make([]*TestResult, 0, len(ids))
+	for _, id := range ids {
+		m, ok := idToNode[id]
+		if !ok {
+			// The node may be removed since the ids were given. Test the others anyway.
+			logrus.Debugf("Skip testing node %v: not found", common.EncodeCursor(id))
+			continue
+		}
+		rs = append(rs, &TestResult{node: m})
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, testConcurrency)
+	for _, r := range rs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *TestResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			latency, err := checkNode(checkCtx, r.node, u)
+			if err != nil {
+				info := err.Error()
+				r.err = &info
+				return
+			}
+			r.latency = &latency
+		}(r)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	for _, r := range rs {
+		if err = tx.Model(r.node).Updates(map[string]interface{}{
+			"latency":         r.latency,
+			"last_checked_at": now,
+		}).Error; err != nil {
+			return nil, err
+		}
+		r.node.Latency = r.latency
+		r.node.LastCheckedAt = &now
+	}
+	if err = recordHealth(tx, rs, now); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func checkNode(ctx context.Context, m *db.Node, u *url.URL) (latency time.Duration, err error) {
+	var tag string
+	if m.Tag != nil {
+		tag = *m.Tag
+	}
+	d, err := db.NewNodeDialer(m.Link, tag)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	start := time.Now()
+	if u.Scheme == "tcp" {
+		conn, err := d.DialContext(ctx, dae.TcpNetwork(), u.Host)
+		if err != nil {
+			return 0, err
+		}
+		_ = conn.Close()
+		return time.Since(start), nil
+	}
+	cli := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				conn, err := d.DialContext(ctx, dae.TcpNetwork(), addr)
+				if err != nil {
+					return nil, err
+				}
+				return &netproxy.FakeNetConn{Conn: conn}, nil
+			},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	if err = checkStatus(resp.StatusCode); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// checkStatus accepts only successful responses of the test url. Other responses, e.g. error pages of captive portals
+// or proxies, do not prove that the node works.
+func checkStatus(code int) error {
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("unexpected status code: %v", code)
+	}
+	return nil
+}
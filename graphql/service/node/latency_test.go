@@ -0,0 +1,61 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package node
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+func TestCheckStatus(t *testing.T) {
+	for code, ok := range map[int]bool{
+		http.StatusOK:                            true,
+		http.StatusNoContent:                     true,
+		http.StatusFound:                         false,
+		http.StatusForbidden:                     false,
+		http.StatusProxyAuthRequired:             false,
+		http.StatusNetworkAuthenticationRequired: false,
+		http.StatusBadGateway:                    false,
+	} {
+		if err := checkStatus(code); (err == nil) != ok {
+			t.Fatalf("status %v: unexpected result: %v", code, err)
+		}
+	}
+}
+
+func TestTestSkipsMissingNodes(t *testing.T) {
+	if err := db.InitDatabase(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// The link cannot be dialed, so the check fails without network.
+	m := db.Node{Link: "unknown://127.0.0.1:1", Name: "broken", Protocol: "unknown"}
+	if err := db.DB(ctx).Create(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+	u, err := ParseTestUrl("tcp://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := test(ctx, []uint{m.ID + 1, m.ID}, u, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || rs[0].node.ID != m.ID || rs[0].Ok() {
+		t.Fatalf("expected a failed result of node %v only, got %v", m.ID, rs)
+	}
+	var saved db.Node
+	if err = db.DB(ctx).First(&saved, m.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.LastCheckedAt == nil {
+		t.Fatal("expected the check to be saved")
+	}
+}
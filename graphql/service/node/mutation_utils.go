@@ -119,6 +119,13 @@ func AutoUpdateVersionByIds(d *gorm.DB, ids []uint) (err error) {
 	return nil
 }
 
+// ClearDanglingPins unpins groups whose pinned nodes have been removed.
+func ClearDanglingPins(d *gorm.DB) error {
+	return d.Model(&db.Group{}).
+		Where("pinned_node_id is not null and pinned_node_id not in (?)", d.Model(&db.Node{}).Select("id")).
+		Update("pinned_node_id", nil).Error
+}
+
 func Remove(ctx context.Context, _ids []graphql.ID) (n int32, err error) {
 	ids, err := common.DecodeCursorBatch(_ids)
 	if err != nil {
@@ -145,6 +152,9 @@ func Remove(ctx context.Context, _ids []graphql.ID) (n int32, err error) {
 	if q.Error != nil {
 		return 0, q.Error
 	}
+	if err = ClearDanglingPins(tx); err != nil {
+		return 0, err
+	}
 
 	return int32(q.RowsAffected), nil
 }
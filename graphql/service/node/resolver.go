@@ -6,8 +6,11 @@
 package node
 
 import (
+	"context"
+
 	"github.com/daeuniverse/dae-wing/common"
 	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/scalar"
 	"github.com/graph-gophers/graphql-go"
 )
 
@@ -36,3 +39,104 @@ func (r *Resolver) Tag() *string {
 func (r *Resolver) SubscriptionID() *graphql.ID {
 	return common.EncodeNullableCursor(r.Node.SubscriptionID)
 }
+func (r *Resolver) Latency() *scalar.Duration {
+	if r.Node.Latency == nil {
+		return nil
+	}
+	return &scalar.Duration{Duration: *r.Node.Latency}
+}
+func (r *Resolver) LastCheckedAt() *graphql.Time {
+	if r.Node.LastCheckedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.Node.LastCheckedAt}
+}
+
+func (r *TestResult) Node() *Resolver {
+	return &Resolver{Node: r.node}
+}
+
+func (r *TestResult) Ok() bool {
+	return r.err == nil
+}
+
+func (r *TestResult) Latency() *scalar.Duration {
+	if r.latency == nil {
+		return nil
+	}
+	return &scalar.Duration{Duration: *r.latency}
+}
+
+func (r *TestResult) Error() *string {
+	return r.err
+}
+
+func (r *Resolver) stat() (*db.NodeHealthStat, error) {
+	var stat db.NodeHealthStat
+	q := db.DB(context.TODO()).Where("link = ?", r.Node.Link).Limit(1).Find(&stat)
+	if q.Error != nil {
+		return nil, q.Error
+	}
+	if q.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &stat, nil
+}
+func (r *Resolver) Uptime() (*float64, error) {
+	stat, err := r.stat()
+	if err != nil || stat == nil {
+		return nil, err
+	}
+	return &stat.Uptime, nil
+}
+func (r *Resolver) LatencyP50() (*scalar.Duration, error) {
+	stat, err := r.stat()
+	if err != nil || stat == nil || stat.LatencyP50 == nil {
+		return nil, err
+	}
+	return &scalar.Duration{Duration: *stat.LatencyP50}, nil
+}
+func (r *Resolver) LatencyP95() (*scalar.Duration, error) {
+	stat, err := r.stat()
+	if err != nil || stat == nil || stat.LatencyP95 == nil {
+		return nil, err
+	}
+	return &scalar.Duration{Duration: *stat.LatencyP95}, nil
+}
+func (r *Resolver) HealthHistory(args *struct {
+	Since *graphql.Time
+}) (rs []*HealthSampleResolver, err error) {
+	q := db.DB(context.TODO()).Where("link = ?", r.Node.Link)
+	if args.Since != nil {
+		q = q.Where("at >= ?", healthTime(args.Since.Time))
+	}
+	var samples []db.NodeHealthSample
+	if err = q.Order("at").Find(&samples).Error; err != nil {
+		return nil, err
+	}
+	rs = make([]*HealthSampleResolver, 0, len(samples))
+	for i := range samples {
+		rs = append(rs, &HealthSampleResolver{NodeHealthSample: &samples[i]})
+	}
+	return rs, nil
+}
+
+type HealthSampleResolver struct {
+	*db.NodeHealthSample
+}
+
+func (r *HealthSampleResolver) At() graphql.Time {
+	return graphql.Time{Time: r.NodeHealthSample.At}
+}
+func (r *HealthSampleResolver) Checks() int32 {
+	return int32(r.NodeHealthSample.Checks)
+}
+func (r *HealthSampleResolver) Successes() int32 {
+	return int32(r.NodeHealthSample.Successes)
+}
+func (r *HealthSampleResolver) Latency() *scalar.Duration {
+	if r.NodeHealthSample.Latency == nil {
+		return nil
+	}
+	return &scalar.Duration{Duration: *r.NodeHealthSample.Latency}
+}
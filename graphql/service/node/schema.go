@@ -15,6 +15,42 @@ type Node {
 	protocol: String!
 	tag: String
 	subscriptionID: ID
+	# latency is measured by the last testNodes. Null means the test failed or never ran.
+	latency: Duration
+	lastCheckedAt: Time
+	# uptime is the percentage of successful checks in the last 7 days. Null means the node was never checked.
+	uptime: Float
+	# latencyP50 and latencyP95 are percentiles of latencies of successful checks in the last 7 days.
+	latencyP50: Duration
+	latencyP95: Duration
+	# healthHistory returns health samples since the time, or all kept samples (30 days) if since is null. Samples
+	# older than a day are merged by hour.
+	healthHistory(since: Time): [NodeHealthSample!]!
+}
+type NodeHealthSample {
+	at: Time!
+	checks: Int!
+	successes: Int!
+	# latency is the average latency of successful checks. Null means no check succeeded.
+	latency: Duration
+}
+type NodeTestResult {
+	node: Node!
+	ok: Boolean!
+	latency: Duration
+	error: String
+}
+enum NodeOrderBy {
+	ID
+	NAME
+	# LATENCY puts nodes without latency last in ascending order.
+	LATENCY
+	LAST_CHECKED_AT
+	# UPTIME puts nodes without uptime last in descending order.
+	UPTIME
+	# LATENCY_P50 and LATENCY_P95 put nodes without latency last in ascending order.
+	LATENCY_P50
+	LATENCY_P95
 }
 type NodesConnection {
 	totalCount: Int!
@@ -0,0 +1,359 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+	pkgOidc "github.com/daeuniverse/dae-wing/pkg/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// pendingTimeout is how long an authorization request stays valid.
+	pendingTimeout = 10 * time.Minute
+	// maxPending limits pending authorization requests because they can be created without authentication.
+	maxPending = 1024
+	// providerTTL is how long the discovered provider metadata is cached.
+	providerTTL = 10 * time.Minute
+)
+
+var ErrNotConfigured = fmt.Errorf("single sign-on is not configured")
+
+type RoleMapping struct {
+	Value string `json:"value"`
+	Role  string `json:"role"`
+}
+
+type ConfigInput struct {
+	Issuer        string
+	ClientId      string
+	ClientSecret  *string
+	Scopes        *[]string
+	UsernameClaim *string
+	RoleClaim     *string
+	RoleMapping   *[]struct {
+		Value string
+		Role  string
+	}
+	DefaultRole *string
+}
+
+// Identity is an authenticated user of the OpenID provider.
+type Identity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Role     string
+}
+
+type pending struct {
+	issuer      string
+	nonce       string
+	verifier    string
+	redirectUri string
+	expiresAt   time.Time
+
+	// identity is set if the request has been authenticated with code and is held by Hold.
+	identity *Identity
+	code     string
+}
+
+var (
+	mu             sync.Mutex
+	pendings       = map[string]*pending{}
+	cachedIssuer   string
+	cachedAt       time.Time
+	cachedProvider *pkgOidc.Provider
+
+	// HttpClient is used to talk to the OpenID provider.
+	HttpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+func parseRoleMapping(s string) (mapping []RoleMapping, err error) {
+	if err = json.Unmarshal([]byte(s), &mapping); err != nil {
+		return nil, fmt.Errorf("bad role mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+// GetConfig returns the config, or nil if single sign-on is not configured.
+func GetConfig(d *gorm.DB) (*db.OidcConfig, error) {
+	var m db.OidcConfig
+	if err := d.Model(&db.OidcConfig{}).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SetConfig replaces the config. Null input removes the config and disables single sign-on.
+func SetConfig(ctx context.Context, input *ConfigInput) (err error) {
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	old, err := GetConfig(tx)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		return tx.Where("1 = 1").Delete(&db.OidcConfig{}).Error
+	}
+	u, err := url.Parse(input.Issuer)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("bad issuer: %v", input.Issuer)
+	}
+	m := db.OidcConfig{
+		Issuer:        strings.TrimSuffix(input.Issuer, "/"),
+		ClientID:      input.ClientId,
+		Scopes:        "openid profile email",
+		UsernameClaim: "preferred_username",
+		RoleClaim:     "groups",
+		RoleMapping:   "[]",
+	}
+	if input.ClientSecret != nil {
+		m.ClientSecret = *input.ClientSecret
+	} else if old != nil {
+		m.ClientSecret = old.ClientSecret
+	}
+	if input.Scopes != nil {
+		scopes := *input.Scopes
+		hasOpenid := false
+		for _, s := range scopes {
+			if s == "openid" {
+				hasOpenid = true
+			}
+		}
+		if !hasOpenid {
+			scopes = append([]string{"openid"}, scopes...)
+		}
+		m.Scopes = strings.Join(scopes, " ")
+	}
+	if input.UsernameClaim != nil {
+		m.UsernameClaim = *input.UsernameClaim
+	}
+	if input.RoleClaim != nil {
+		m.RoleClaim = *input.RoleClaim
+	}
+	if input.RoleMapping != nil {
+		var mapping []RoleMapping
+		for _, r := range *input.RoleMapping {
+			role := strings.ToLower(r.Role)
+			if err = db.ValidateRole(role); err != nil {
+				return err
+			}
+			mapping = append(mapping, RoleMapping{Value: r.Value, Role: role})
+		}
+		b, err := json.Marshal(mapping)
+		if err != nil {
+			return err
+		}
+		m.RoleMapping = string(b)
+	}
+	if input.DefaultRole != nil {
+		m.DefaultRole = strings.ToLower(*input.DefaultRole)
+		if err = db.ValidateRole(m.DefaultRole); err != nil {
+			return err
+		}
+	}
+	if err = tx.Where("1 = 1").Delete(&db.OidcConfig{}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&m).Error
+}
+
+func provider(ctx context.Context, issuer string) (*pkgOidc.Provider, error) {
+	mu.Lock()
+	if cachedProvider != nil && cachedIssuer == issuer && time.Since(cachedAt) < providerTTL {
+		p := cachedProvider
+		mu.Unlock()
+		return p, nil
+	}
+	mu.Unlock()
+	p, err := pkgOidc.Discover(ctx, HttpClient, issuer)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	cachedProvider, cachedIssuer, cachedAt = p, issuer, time.Now()
+	mu.Unlock()
+	return p, nil
+}
+
+// AuthorizeUrl starts an authorization request and returns the URL of the OpenID provider to visit.
+// The provider will redirect back to redirectUri with "code" and "state" in the query.
+func AuthorizeUrl(ctx context.Context, redirectUri string) (string, error) {
+	c, err := GetConfig(db.DB(ctx))
+	if err != nil {
+		return "", err
+	}
+	if c == nil {
+		return "", ErrNotConfigured
+	}
+	if u, err := url.Parse(redirectUri); err != nil || !u.IsAbs() {
+		return "", fmt.Errorf("redirectUri should be an absolute URL")
+	}
+	p, err := provider(ctx, c.Issuer)
+	if err != nil {
+		return "", err
+	}
+	state, err := pkgOidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := pkgOidc.NewState()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := pkgOidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+	mu.Lock()
+	now := time.Now()
+	for k, v := range pendings {
+		if now.After(v.expiresAt) {
+			delete(pendings, k)
+		}
+	}
+	if len(pendings) >= maxPending {
+		mu.Unlock()
+		return "", fmt.Errorf("too many pending sign-in requests; try again later")
+	}
+	pendings[state] = &pending{
+		issuer:      c.Issuer,
+		nonce:       nonce,
+		verifier:    verifier,
+		redirectUri: redirectUri,
+		expiresAt:   now.Add(pendingTimeout),
+	}
+	mu.Unlock()
+	return p.AuthCodeURL(c.ClientID, redirectUri, strings.Fields(c.Scopes), state, nonce, verifier), nil
+}
+
+// Authenticate finishes the authorization request identified by state and returns the identity.
+// The request is used up unless it is held again by Hold.
+func Authenticate(ctx context.Context, code string, state string) (*Identity, error) {
+	mu.Lock()
+	pd, ok := pendings[state]
+	delete(pendings, state)
+	mu.Unlock()
+	if !ok || time.Now().After(pd.expiresAt) {
+		return nil, fmt.Errorf("sign-in request is invalid or expired")
+	}
+	c, err := GetConfig(db.DB(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNotConfigured
+	}
+	if c.Issuer != pd.issuer {
+		return nil, fmt.Errorf("single sign-on config changed; please sign in again")
+	}
+	if pd.identity != nil {
+		if pd.code != code {
+			return nil, fmt.Errorf("sign-in request is invalid or expired")
+		}
+		return pd.identity, nil
+	}
+	p, err := provider(ctx, c.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := p.Exchange(ctx, c.ClientID, c.ClientSecret, code, pd.redirectUri, pd.verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := p.Verify(ctx, idToken, c.ClientID, pd.nonce)
+	if err != nil {
+		return nil, err
+	}
+	subject, _ := claims.GetSubject()
+	role, err := mapRole(c, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Issuer:   c.Issuer,
+		Subject:  subject,
+		Username: username(c, claims, subject),
+		Role:     role,
+	}, nil
+}
+
+// Hold keeps the identity authenticated by the request identified by code and state, so that Authenticate can be
+// called again with them, e.g. after a second factor is asked for. The code cannot be exchanged twice.
+func Hold(code string, state string, identity *Identity) {
+	mu.Lock()
+	defer mu.Unlock()
+	pendings[state] = &pending{
+		issuer:    identity.Issuer,
+		expiresAt: time.Now().Add(pendingTimeout),
+		identity:  identity,
+		code:      code,
+	}
+}
+
+func username(c *db.OidcConfig, claims jwt.MapClaims, subject string) string {
+	for _, claim := range []string{c.UsernameClaim, "email"} {
+		if s, ok := claims[claim].(string); ok && s != "" {
+			return s
+		}
+	}
+	return subject
+}
+
+// mapRole returns the most privileged role matched by the claim.
+func mapRole(c *db.OidcConfig, claims jwt.MapClaims) (string, error) {
+	mapping, err := parseRoleMapping(c.RoleMapping)
+	if err != nil {
+		return "", err
+	}
+	var values []string
+	switch v := claims[c.RoleClaim].(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, vv := range v {
+			if s, ok := vv.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	var role string
+	for _, m := range mapping {
+		for _, v := range values {
+			if v == m.Value && (role == "" || db.RoleSatisfies(m.Role, role)) {
+				role = m.Role
+			}
+		}
+	}
+	if role == "" {
+		role = c.DefaultRole
+	}
+	if role == "" {
+		return "", fmt.Errorf("no role is mapped for this account")
+	}
+	return role, nil
+}
@@ -0,0 +1,73 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMapRole(t *testing.T) {
+	const mapping = `[{"value":"viewers","role":"viewer"},{"value":"admins","role":"admin"},{"value":"ops","role":"operator"}]`
+	tests := []struct {
+		name        string
+		claims      jwt.MapClaims
+		defaultRole string
+		want        string
+		wantErr     bool
+	}{
+		{name: "string claim", claims: jwt.MapClaims{"groups": "ops"}, want: db.RoleOperator},
+		{name: "most privileged of list", claims: jwt.MapClaims{"groups": []interface{}{"viewers", "admins", "ops"}}, want: db.RoleAdmin},
+		{name: "non-string values ignored", claims: jwt.MapClaims{"groups": []interface{}{1, "viewers"}}, want: db.RoleViewer},
+		{name: "no match falls back to default", claims: jwt.MapClaims{"groups": []interface{}{"guests"}}, defaultRole: db.RoleViewer, want: db.RoleViewer},
+		{name: "missing claim falls back to default", claims: jwt.MapClaims{}, defaultRole: db.RoleOperator, want: db.RoleOperator},
+		{name: "no match without default", claims: jwt.MapClaims{"groups": "guests"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &db.OidcConfig{RoleClaim: "groups", RoleMapping: mapping, DefaultRole: tt.defaultRole}
+			got, err := mapRole(c, tt.claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHold(t *testing.T) {
+	if err := db.InitDatabase(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const issuer = "https://idp.example"
+	if err := db.DB(ctx).Create(&db.OidcConfig{Issuer: issuer, ClientID: "wing"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	identity := &Identity{Issuer: issuer, Subject: "alice-id", Username: "alice", Role: db.RoleViewer}
+
+	Hold("code", "state", identity)
+	got, err := Authenticate(ctx, "code", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != identity {
+		t.Fatalf("expected the held identity, got %+v", got)
+	}
+	if _, err = Authenticate(ctx, "code", "state"); err == nil {
+		t.Fatal("expected the held identity to be used up")
+	}
+
+	Hold("code", "state", identity)
+	if _, err = Authenticate(ctx, "other", "state"); err == nil {
+		t.Fatal("expected a different code to be rejected")
+	}
+}
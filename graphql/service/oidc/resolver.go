@@ -0,0 +1,61 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package oidc
+
+import (
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+type Resolver struct {
+	*db.OidcConfig
+}
+
+func (r *Resolver) Issuer() string {
+	return r.OidcConfig.Issuer
+}
+
+func (r *Resolver) ClientId() string {
+	return r.OidcConfig.ClientID
+}
+
+func (r *Resolver) Scopes() []string {
+	return strings.Fields(r.OidcConfig.Scopes)
+}
+
+func (r *Resolver) UsernameClaim() string {
+	return r.OidcConfig.UsernameClaim
+}
+
+func (r *Resolver) RoleClaim() string {
+	return r.OidcConfig.RoleClaim
+}
+
+func (r *Resolver) RoleMapping() ([]*RoleMappingResolver, error) {
+	mapping, err := parseRoleMapping(r.OidcConfig.RoleMapping)
+	if err != nil {
+		return nil, err
+	}
+	var rs []*RoleMappingResolver
+	for _, m := range mapping {
+		rs = append(rs, &RoleMappingResolver{Value: m.Value, Role: strings.ToUpper(m.Role)})
+	}
+	return rs, nil
+}
+
+func (r *Resolver) DefaultRole() *string {
+	if r.OidcConfig.DefaultRole == "" {
+		return nil
+	}
+	role := strings.ToUpper(r.OidcConfig.DefaultRole)
+	return &role
+}
+
+type RoleMappingResolver struct {
+	Value string
+	Role  string
+}
@@ -0,0 +1,42 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package oidc
+
+func Schema() (string, error) {
+	return `
+type OidcConfig {
+	issuer: String!
+	clientId: String!
+	scopes: [String!]!
+	# usernameClaim is the claim used as the username of newly created users. Fall back to "email" and "sub".
+	usernameClaim: String!
+	# roleClaim is the claim matched against roleMapping. It can be a string or an array of strings.
+	roleClaim: String!
+	roleMapping: [OidcRoleMapping!]!
+	# defaultRole is given to users matching no mapping. Null means such users are rejected.
+	defaultRole: Role
+}
+type OidcRoleMapping {
+	value: String!
+	role: Role!
+}
+input OidcConfigInput {
+	issuer: String!
+	clientId: String!
+	# clientSecret keeps the current secret if null.
+	clientSecret: String
+	scopes: [String!]
+	usernameClaim: String
+	roleClaim: String
+	roleMapping: [OidcRoleMappingInput!]
+	defaultRole: Role
+}
+input OidcRoleMappingInput {
+	value: String!
+	role: Role!
+}
+`, nil
+}
@@ -0,0 +1,235 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/dae"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/scalar"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTimeout   = 5 * time.Second
+	MaxTimeout       = time.Minute
+	DefaultDnsServer = "1.1.1.1:53"
+)
+
+var (
+	lastMu      sync.RWMutex
+	lastResults []*Result
+)
+
+type Input struct {
+	Kind    string
+	Target  string
+	Server  *string
+	Timeout *scalar.Duration
+}
+
+func (i *Input) model() (*db.RunProbe, error) {
+	m := &db.RunProbe{
+		Kind:    i.Kind,
+		Target:  i.Target,
+		Timeout: DefaultTimeout,
+	}
+	if i.Timeout != nil {
+		if i.Timeout.Duration <= 0 || i.Timeout.Duration > MaxTimeout {
+			return nil, fmt.Errorf("timeout should be positive and no more than %v", MaxTimeout)
+		}
+		m.Timeout = i.Timeout.Duration
+	}
+	switch i.Kind {
+	case db.RunProbeKindHttp:
+		u, err := url.Parse(i.Target)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("bad url of HTTP probe: %v", i.Target)
+		}
+	case db.RunProbeKindDns:
+		if i.Target == "" {
+			return nil, fmt.Errorf("domain of DNS probe is required")
+		}
+		m.Server = DefaultDnsServer
+		if i.Server != nil {
+			if _, _, err := net.SplitHostPort(*i.Server); err != nil {
+				return nil, fmt.Errorf("bad server of DNS probe: %w", err)
+			}
+			m.Server = *i.Server
+		}
+	default:
+		return nil, fmt.Errorf("unknown probe kind: %v", i.Kind)
+	}
+	return m, nil
+}
+
+// Set replaces all probes.
+func Set(ctx context.Context, inputs []*Input, rollbackOnFailure bool) (n int32, err error) {
+	var models []*db.RunProbe
+	for _, i := range inputs {
+		m, err := i.model()
+		if err != nil {
+			return 0, err
+		}
+		models = append(models, m)
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	if err = tx.Where("1 = 1").Delete(&db.RunProbe{}).Error; err != nil {
+		return 0, err
+	}
+	if len(models) > 0 {
+		if err = tx.Create(models).Error; err != nil {
+			return 0, err
+		}
+	}
+	var sys db.System
+	if err = tx.Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Model(&sys).Update("rollback_on_probe_failure", rollbackOnFailure).Error; err != nil {
+		return 0, err
+	}
+	return int32(len(models)), nil
+}
+
+// Run checks all probes concurrently through the control plane. It returns nil if there is no control plane to
+// probe through, e.g. in api-only mode.
+func Run(d *gorm.DB) (results []*Result, err error) {
+	var probes []db.RunProbe
+	if err = d.Model(&db.RunProbe{}).Order("id").Find(&probes).Error; err != nil {
+		return nil, err
+	}
+	if _, err = dae.ControlPlane(); err != nil || len(probes) == 0 {
+		return nil, nil
+	}
+	results = make([]*Result, len(probes))
+	var wg sync.WaitGroup
+	for i := range probes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = check(&probes[i])
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func check(p *db.RunProbe) *Result {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	start := time.Now()
+	var err error
+	switch p.Kind {
+	case db.RunProbeKindHttp:
+		err = checkHttp(ctx, p.Target)
+	case db.RunProbeKindDns:
+		err = checkDns(ctx, p.Target, p.Server)
+	default:
+		err = fmt.Errorf("unknown probe kind: %v", p.Kind)
+	}
+	r := &Result{
+		probe:     p,
+		checkedAt: start,
+		latency:   time.Since(start),
+	}
+	if err != nil {
+		info := err.Error()
+		r.err = &info
+	}
+	return r
+}
+
+// checkHttp succeeds if the url responds with a 2xx or 3xx status.
+func checkHttp(ctx context.Context, u string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	client := http.Client{
+		Transport: dae.HttpTransport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status: %v", resp.Status)
+	}
+	return nil
+}
+
+// checkDns succeeds if the domain resolves to any address. The query is sent over TCP through the control plane.
+func checkDns(ctx context.Context, domain string, server string) error {
+	resolver := net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dae.RouteDialTcp(ctx, server)
+		},
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", domain)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no dns record: %v", domain)
+	}
+	return nil
+}
+
+// Failed returns failed results.
+func Failed(results []*Result) (failed []*Result) {
+	for _, r := range results {
+		if r.err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// SetLast records results of the last run.
+func SetLast(results []*Result) {
+	lastMu.Lock()
+	defer lastMu.Unlock()
+	lastResults = results
+}
+
+// Last returns results of the last run.
+func Last() []*Result {
+	lastMu.RLock()
+	defer lastMu.RUnlock()
+	return append([]*Result{}, lastResults...)
+}
+
+// RollbackOnFailure returns whether to load the previous config back if any probe fails.
+func RollbackOnFailure(d *gorm.DB) (bool, error) {
+	var sys db.System
+	if err := d.Model(&db.System{}).FirstOrCreate(&sys).Error; err != nil {
+		return false, err
+	}
+	return sys.RollbackOnProbeFailure, nil
+}
@@ -0,0 +1,78 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package probe
+
+import (
+	"time"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/scalar"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type Resolver struct {
+	*db.RunProbe
+}
+
+func (r *Resolver) ID() graphql.ID {
+	return common.EncodeCursor(r.RunProbe.ID)
+}
+
+func (r *Resolver) Kind() string {
+	return r.RunProbe.Kind
+}
+
+func (r *Resolver) Target() string {
+	return r.RunProbe.Target
+}
+
+func (r *Resolver) Server() *string {
+	if r.RunProbe.Server == "" {
+		return nil
+	}
+	return &r.RunProbe.Server
+}
+
+func (r *Resolver) Timeout() scalar.Duration {
+	return scalar.Duration{Duration: r.RunProbe.Timeout}
+}
+
+type SettingsResolver struct {
+	Probes            []*Resolver
+	RollbackOnFailure bool
+}
+
+type Result struct {
+	probe     *db.RunProbe
+	checkedAt time.Time
+	latency   time.Duration
+	err       *string
+}
+
+func (r *Result) Kind() string {
+	return r.probe.Kind
+}
+
+func (r *Result) Target() string {
+	return r.probe.Target
+}
+
+func (r *Result) Ok() bool {
+	return r.err == nil
+}
+
+func (r *Result) CheckedAt() graphql.Time {
+	return graphql.Time{Time: r.checkedAt}
+}
+
+func (r *Result) Latency() scalar.Duration {
+	return scalar.Duration{Duration: r.latency}
+}
+
+func (r *Result) Error() *string {
+	return r.err
+}
@@ -0,0 +1,46 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package probe
+
+func Schema() (string, error) {
+	return `
+enum RunProbeKind {
+	# HTTP requests the target url and succeeds on a 2xx or 3xx status.
+	HTTP
+	# DNS looks up the target domain from the server over TCP.
+	DNS
+}
+type RunProbe {
+	id: ID!
+	kind: RunProbeKind!
+	target: String!
+	server: String
+	timeout: Duration!
+}
+input RunProbeInput {
+	kind: RunProbeKind!
+	# target is an url for HTTP probes and a domain for DNS probes.
+	target: String!
+	# server is the dns server of DNS probes. Default to "1.1.1.1:53".
+	server: String
+	# timeout defaults to 5s and is at most 1m.
+	timeout: Duration
+}
+type RunProbeSettings {
+	probes: [RunProbe!]!
+	# rollbackOnFailure is whether to load the previous config back if any probe fails after a run.
+	rollbackOnFailure: Boolean!
+}
+type RunProbeResult {
+	kind: RunProbeKind!
+	target: String!
+	ok: Boolean!
+	checkedAt: Time!
+	latency: Duration!
+	error: String
+}
+`, nil
+}
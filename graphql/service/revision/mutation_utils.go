@@ -0,0 +1,230 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package revision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/pkg/udiff"
+	"github.com/graph-gophers/graphql-go"
+	"gorm.io/gorm"
+)
+
+// Record appends a revision of the target. The author is taken from the context.
+func Record(ctx context.Context, d *gorm.DB, kind string, targetId uint, version uint, content string, restoredFrom *uint) (*db.Revision, error) {
+	m := db.Revision{
+		Kind:           kind,
+		TargetID:       targetId,
+		Version:        version,
+		Content:        content,
+		CreatedAt:      time.Now(),
+		RestoredFromID: restoredFrom,
+	}
+	if u, ok := ctx.Value("user").(*db.User); ok {
+		m.AuthorID = &u.ID
+		m.Author = u.Username
+	}
+	if err := d.Create(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// EnsureBaseline records the current content of the target if it has no revision yet, which is the case
+// for records created before revisions were introduced.
+func EnsureBaseline(d *gorm.DB, kind string, targetId uint, version uint, content string) error {
+	var cnt int64
+	if err := d.Model(&db.Revision{}).
+		Where("kind = ?", kind).
+		Where("target_id = ?", targetId).
+		Where("target_removed_at is null").
+		Count(&cnt).Error; err != nil {
+		return err
+	}
+	if cnt > 0 {
+		return nil
+	}
+	return d.Create(&db.Revision{
+		Kind:      kind,
+		TargetID:  targetId,
+		Version:   version,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// Detach keeps revisions of the removed target apart from a new target that may reuse its id.
+func Detach(d *gorm.DB, kind string, targetId uint, name string) error {
+	return d.Model(&db.Revision{}).
+		Where("kind = ?", kind).
+		Where("target_id = ?", targetId).
+		Where("target_removed_at is null").
+		Updates(map[string]interface{}{
+			"target_removed_at": time.Now(),
+			"target_name":       name,
+		}).Error
+}
+
+// Target creates and updates targets of a kind for Restore.
+type Target struct {
+	// Insert creates a target with the name and the content using given db.
+	Insert func(ctx context.Context, d *gorm.DB, name string, content string, restoredFrom *uint) (*db.Revision, error)
+	// Update sets the content of the target in its own transaction.
+	Update func(ctx context.Context, id uint, content string, restoredFrom *uint) (*db.Revision, error)
+}
+
+// Restore sets the target to the content of given revision and returns the new revision. If the target was
+// removed, a new one is created with the content and takes over the revisions of the removed one, so that restoring
+// again updates it instead of creating another.
+func Restore(ctx context.Context, rev *db.Revision, t Target) (newRev *db.Revision, err error) {
+	if rev.TargetRemovedAt == nil {
+		return t.Update(ctx, rev.TargetID, rev.Content, &rev.ID)
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	var m db.Revision
+	if err = tx.Model(&db.Revision{}).Where("id = ?", rev.ID).First(&m).Error; err != nil {
+		return nil, err
+	}
+	if m.TargetRemovedAt == nil {
+		return nil, fmt.Errorf("the %v has been restored; restore the revision again to update it", m.Kind)
+	}
+	if newRev, err = t.Insert(ctx, tx, m.TargetName, m.Content, &m.ID); err != nil {
+		return nil, err
+	}
+	if err = tx.Model(&db.Revision{}).
+		Where("kind = ?", m.Kind).
+		Where("target_id = ?", m.TargetID).
+		Where("target_removed_at = ?", *m.TargetRemovedAt).
+		Updates(map[string]interface{}{
+			"target_id":         newRev.TargetID,
+			"target_removed_at": nil,
+			"target_name":       "",
+		}).Error; err != nil {
+		return nil, err
+	}
+	return newRev, nil
+}
+
+// Purge removes revisions of removed targets.
+func Purge(ctx context.Context, _ids []graphql.ID) (n int32, err error) {
+	ids, err := common.DecodeCursorBatch(_ids)
+	if err != nil {
+		return 0, err
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	var cnt int64
+	if err = tx.Model(&db.Revision{}).
+		Where("id in ?", ids).
+		Where("target_removed_at is null").
+		Count(&cnt).Error; err != nil {
+		return 0, err
+	}
+	if cnt > 0 {
+		return 0, fmt.Errorf("cannot purge revisions of existing configs")
+	}
+	q := tx.Where("id in ?", ids).Delete(&db.Revision{})
+	if q.Error != nil {
+		return 0, q.Error
+	}
+	return int32(q.RowsAffected), nil
+}
+
+func Get(ctx context.Context, _id graphql.ID) (*db.Revision, error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return nil, err
+	}
+	var m db.Revision
+	if err = db.DB(ctx).Model(&db.Revision{}).Where("id = ?", id).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("no such revision")
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// List lists revisions of the target from the newest to the oldest.
+func List(ctx context.Context, kind string, _targetId graphql.ID, first *int32, _after *graphql.ID) (rs []*Resolver, err error) {
+	targetId, err := common.DecodeCursor(_targetId)
+	if err != nil {
+		return nil, err
+	}
+	q := db.DB(ctx).Model(&db.Revision{}).
+		Where("kind = ?", kind).
+		Where("target_id = ?", targetId).
+		Where("target_removed_at is null")
+	return list(q, first, _after)
+}
+
+// ListRemoved lists revisions of removed targets from the newest to the oldest.
+func ListRemoved(ctx context.Context, kind string, first *int32, _after *graphql.ID) (rs []*Resolver, err error) {
+	q := db.DB(ctx).Model(&db.Revision{}).
+		Where("kind = ?", kind).
+		Where("target_removed_at is not null")
+	return list(q, first, _after)
+}
+
+func list(q *gorm.DB, first *int32, _after *graphql.ID) (rs []*Resolver, err error) {
+	if _after != nil {
+		after, err := common.DecodeCursor(*_after)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("id < ?", after)
+	}
+	if first != nil {
+		q = q.Limit(int(*first))
+	}
+	var models []db.Revision
+	if err = q.Order("id DESC").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	for _, _m := range models {
+		m := _m
+		rs = append(rs, &Resolver{Revision: &m})
+	}
+	return rs, nil
+}
+
+// Diff returns the unified diff between contents of two revisions of the same kind.
+func Diff(ctx context.Context, _from graphql.ID, _to graphql.ID) (string, error) {
+	from, err := Get(ctx, _from)
+	if err != nil {
+		return "", err
+	}
+	to, err := Get(ctx, _to)
+	if err != nil {
+		return "", err
+	}
+	if from.Kind != to.Kind {
+		return "", fmt.Errorf("cannot compare a %v revision with a %v revision", from.Kind, to.Kind)
+	}
+	return udiff.Unified(
+		fmt.Sprintf("%v/%v (version %v)", from.Kind, common.EncodeCursor(from.ID), from.Version),
+		fmt.Sprintf("%v/%v (version %v)", to.Kind, common.EncodeCursor(to.ID), to.Version),
+		from.Content, to.Content, 3,
+	), nil
+}
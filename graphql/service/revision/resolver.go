@@ -0,0 +1,71 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package revision
+
+import (
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type Resolver struct {
+	*db.Revision
+}
+
+func (r *Resolver) ID() graphql.ID {
+	return common.EncodeCursor(r.Revision.ID)
+}
+
+func (r *Resolver) Kind() string {
+	return strings.ToUpper(r.Revision.Kind)
+}
+
+func (r *Resolver) TargetId() graphql.ID {
+	return common.EncodeCursor(r.Revision.TargetID)
+}
+
+func (r *Resolver) Version() int32 {
+	return int32(r.Revision.Version)
+}
+
+func (r *Resolver) Content() string {
+	return r.Revision.Content
+}
+
+func (r *Resolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.Revision.CreatedAt}
+}
+
+func (r *Resolver) Author() *string {
+	if r.Revision.Author == "" {
+		return nil
+	}
+	return &r.Revision.Author
+}
+
+func (r *Resolver) RestoredFrom() *graphql.ID {
+	if r.Revision.RestoredFromID == nil {
+		return nil
+	}
+	id := common.EncodeCursor(*r.Revision.RestoredFromID)
+	return &id
+}
+
+func (r *Resolver) TargetRemovedAt() *graphql.Time {
+	if r.Revision.TargetRemovedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.Revision.TargetRemovedAt}
+}
+
+func (r *Resolver) TargetName() *string {
+	if r.Revision.TargetRemovedAt == nil {
+		return nil
+	}
+	return &r.Revision.TargetName
+}
@@ -0,0 +1,34 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package revision
+
+func Schema() (string, error) {
+	return `
+enum RevisionKind {
+	CONFIG
+	DNS
+	ROUTING
+}
+type Revision {
+	id: ID!
+	kind: RevisionKind!
+	# targetId is the ID of the config, dns or routing.
+	targetId: ID!
+	version: Int!
+	# content is the complete section, e.g. "routing { ... }".
+	content: String!
+	createdAt: Time!
+	# author is null if unknown.
+	author: String
+	# restoredFrom is the ID of the revision this one was restored from.
+	restoredFrom: ID
+	# targetRemovedAt is when the target was removed, or null if it still exists.
+	targetRemovedAt: Time
+	# targetName is the name of the removed target, or null if it still exists.
+	targetName: String
+}
+`, nil
+}
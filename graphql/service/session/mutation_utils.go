@@ -0,0 +1,95 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+)
+
+// lastSeenPrecision limits how often LastSeenAt is written back.
+const lastSeenPrecision = time.Minute
+
+var ErrRevoked = fmt.Errorf("session has been revoked")
+
+// Create records a new session for the user and returns its JTI.
+func Create(d *gorm.DB, userId uint, clientIp string, userAgent string, expiresAt time.Time) (jti string, err error) {
+	jti, err = gonanoid.New()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	// Clean up expired sessions of the user by the way.
+	if err = d.Where("user_id = ?", userId).
+		Where("expires_at < ?", now).
+		Delete(&db.Session{}).Error; err != nil {
+		return "", err
+	}
+	if err = d.Create(&db.Session{
+		Jti:        jti,
+		ClientIp:   clientIp,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+		UserID:     userId,
+	}).Error; err != nil {
+		return "", err
+	}
+	return jti, nil
+}
+
+// Touch checks the session is not revoked and updates its last-seen time.
+func Touch(ctx context.Context, userId uint, jti string, clientIp string) error {
+	var m db.Session
+	if err := db.DB(ctx).
+		Where("jti = ?", jti).
+		Where("user_id = ?", userId).
+		First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRevoked
+		}
+		return err
+	}
+	now := time.Now()
+	if now.Sub(m.LastSeenAt) > lastSeenPrecision || m.ClientIp != clientIp {
+		if err := db.DB(ctx).Model(&db.Session{ID: m.ID}).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"client_ip":    clientIp,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Revoke(ctx context.Context, u *db.User, _id graphql.ID) (n int32, err error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return 0, err
+	}
+	q := db.DB(ctx).
+		Where("id = ?", id).
+		Where("user_id = ?", u.ID).
+		Delete(&db.Session{})
+	if q.Error != nil {
+		return 0, q.Error
+	}
+	return int32(q.RowsAffected), nil
+}
+
+// RevokeAll revokes all sessions of the user.
+func RevokeAll(d *gorm.DB, userId uint) error {
+	return d.Where("user_id = ?", userId).Delete(&db.Session{}).Error
+}
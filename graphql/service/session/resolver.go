@@ -0,0 +1,46 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package session
+
+import (
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type Resolver struct {
+	*db.Session
+	// CurrentJti is the JTI of the token used by the request.
+	CurrentJti string
+}
+
+func (r *Resolver) ID() graphql.ID {
+	return common.EncodeCursor(r.Session.ID)
+}
+
+func (r *Resolver) ClientIp() string {
+	return r.Session.ClientIp
+}
+
+func (r *Resolver) UserAgent() string {
+	return r.Session.UserAgent
+}
+
+func (r *Resolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.Session.CreatedAt}
+}
+
+func (r *Resolver) LastSeenAt() graphql.Time {
+	return graphql.Time{Time: r.Session.LastSeenAt}
+}
+
+func (r *Resolver) ExpiresAt() graphql.Time {
+	return graphql.Time{Time: r.Session.ExpiresAt}
+}
+
+func (r *Resolver) Current() bool {
+	return r.CurrentJti != "" && r.Session.Jti == r.CurrentJti
+}
@@ -0,0 +1,21 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package session
+
+func Schema() (string, error) {
+	return `
+type Session {
+	id: ID!
+	clientIp: String!
+	userAgent: String!
+	createdAt: Time!
+	lastSeenAt: Time!
+	expiresAt: Time!
+	# current indicates whether the session is the one making this request.
+	current: Boolean!
+}
+`, nil
+}
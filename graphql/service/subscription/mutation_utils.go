@@ -18,7 +18,9 @@ import (
 	"github.com/daeuniverse/dae-wing/dae"
 	"github.com/daeuniverse/dae-wing/db"
 	"github.com/daeuniverse/dae-wing/graphql/internal"
+	"github.com/daeuniverse/dae-wing/graphql/service/event"
 	"github.com/daeuniverse/dae-wing/graphql/service/node"
+	"github.com/daeuniverse/dae-wing/metrics"
 	"github.com/daeuniverse/dae/common/subscription"
 	"github.com/go-co-op/gocron"
 	"github.com/graph-gophers/graphql-go"
@@ -95,13 +97,24 @@ func _fetchLinks(subscriptionLink string, transport http.RoundTripper, timeout t
 }
 
 func Import(c *gorm.DB, rollbackError bool, argument *internal.ImportArgument) (r *ImportResult, err error) {
-	if err = argument.ValidateTag(); err != nil {
+	links, err := Fetch(argument)
+	if err != nil {
 		return nil, err
 	}
-	links, err := fetchLinks(argument.Link)
-	if err != nil {
+	return ImportLinks(c, rollbackError, argument, links)
+}
+
+// Fetch resolves the subscription to node links. It goes through the network and should not be called in a
+// transaction.
+func Fetch(argument *internal.ImportArgument) (links []string, err error) {
+	if err = argument.ValidateTag(); err != nil {
 		return nil, err
 	}
+	return fetchLinks(argument.Link)
+}
+
+// ImportLinks imports the subscription with node links returned by Fetch.
+func ImportLinks(c *gorm.DB, rollbackError bool, argument *internal.ImportArgument, links []string) (r *ImportResult, err error) {
 	/// Create a subscription model.
 	m := db.Subscription{
 		ID:        0,
@@ -261,6 +274,11 @@ func Update(ctx context.Context, _id graphql.ID) (r *Resolver, err error) {
 func UpdateById(ctx context.Context, subId uint) (sub *db.Subscription, err error) {
 	// Fetch node links.
 	var m db.Subscription
+	// Deferred first to publish after the transaction ends.
+	defer func() {
+		event.PublishSubscriptionUpdate(subId, m.Tag, err)
+		metrics.ObserveSubscriptionUpdate(string(common.EncodeCursor(subId)), m.Tag, err)
+	}()
 	if err = db.DB(ctx).Where(&db.Subscription{ID: subId}).First(&m).Error; err != nil {
 		return nil, err
 	}
@@ -282,6 +300,18 @@ func UpdateById(ctx context.Context, subId uint) (sub *db.Subscription, err erro
                 from nodes
                 inner join group_nodes on group_nodes.node_id = nodes.id
                 where subscription_id = ?`, subId)
+	// Groups pinned to nodes to remove are pinned to the imported nodes with the same links again.
+	var pins []struct {
+		GroupID uint
+		Link    string
+	}
+	if err = tx.Model(&db.Group{}).
+		Select("groups.id as group_id, nodes.link as link").
+		Joins("inner join nodes on nodes.id = groups.pinned_node_id").
+		Where("nodes.subscription_id = ? and nodes.id not in (?)", subId, subQuery).
+		Scan(&pins).Error; err != nil {
+		return nil, err
+	}
 
 	if err = tx.Where("subscription_id = ?", subId).
 		Where("id not in (?)", subQuery).
@@ -289,6 +319,9 @@ func UpdateById(ctx context.Context, subId uint) (sub *db.Subscription, err erro
 		Delete(&db.Node{}).Error; err != nil {
 		return nil, err
 	}
+	if err = node.ClearDanglingPins(tx); err != nil {
+		return nil, err
+	}
 	// Import node links.
 	var args []*internal.ImportArgument
 	for _, link := range links {
@@ -308,6 +341,15 @@ func UpdateById(ctx context.Context, subId uint) (sub *db.Subscription, err erro
 	if !hasAnyCandidate {
 		return nil, fmt.Errorf("interrupt to update subscription: no any valid node can be imported")
 	}
+	for _, pin := range pins {
+		if err = tx.Model(&db.Group{ID: pin.GroupID}).
+			Update("pinned_node_id", tx.Model(&db.Node{}).
+				Select("id").
+				Where("subscription_id = ? and link = ?", subId, pin.Link).
+				Limit(1)).Error; err != nil {
+			return nil, err
+		}
+	}
 	// Update updated_at and return the latest version.
 	if err = tx.Model(&m).
 		Clauses(clause.Returning{}).
@@ -359,6 +401,9 @@ func Remove(ctx context.Context, _ids []graphql.ID) (n int32, err error) {
 		Delete(&db.Node{}).Error; err != nil {
 		return 0, err
 	}
+	if err = node.ClearDanglingPins(tx); err != nil {
+		return 0, err
+	}
 	q := tx.Where("id in ?", ids).
 		Select(clause.Associations).
 		Delete(&db.Subscription{})
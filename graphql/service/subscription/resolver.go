@@ -43,9 +43,11 @@ func (r *Resolver) Info() string {
 	return r.Subscription.Info
 }
 func (r *Resolver) Nodes(args *struct {
-	First *int32
-	After *graphql.ID
+	First   *int32
+	After   *graphql.ID
+	OrderBy *string
+	Desc    *bool
 }) (*node.ConnectionResolver, error) {
 	id := common.EncodeCursor(r.Subscription.ID)
-	return node.NewConnectionResolver(nil, &id, args.First, args.After)
+	return node.NewConnectionResolver(nil, &id, args.First, args.After, args.OrderBy, args.Desc)
 }
@@ -16,7 +16,7 @@ type Subscription {
 	cronEnable: Boolean!
 	status: String!
 	info: String!
-	nodes(first: Int, after: ID): NodesConnection!
+	nodes(first: Int, after: ID, orderBy: NodeOrderBy, desc: Boolean): NodesConnection!
 }
 `, nil
 }
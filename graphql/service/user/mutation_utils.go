@@ -0,0 +1,100 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/daeuniverse/dae-wing/common"
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
+	"gorm.io/gorm"
+)
+
+// EnsureOtherAdmin returns an error if the user with given id is the last admin.
+func EnsureOtherAdmin(d *gorm.DB, id uint) error {
+	var cnt int64
+	if err := d.Model(&db.User{}).
+		Where("role = ?", db.RoleAdmin).
+		Where("id != ?", id).
+		Count(&cnt).Error; err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return fmt.Errorf("cannot remove or demote the last admin")
+	}
+	return nil
+}
+
+func Remove(ctx context.Context, current *db.User, _id graphql.ID) (n int32, err error) {
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return 0, err
+	}
+	if id == current.ID {
+		return 0, fmt.Errorf("cannot remove yourself")
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	if err = EnsureOtherAdmin(tx, id); err != nil {
+		return 0, err
+	}
+	if err = tx.Where("user_id = ?", id).Delete(&db.ApiToken{}).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Where("user_id = ?", id).Delete(&db.Session{}).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Where("user_id = ?", id).Delete(&db.RecoveryCode{}).Error; err != nil {
+		return 0, err
+	}
+	if err = tx.Where("user_id = ?", id).Delete(&db.OidcIdentity{}).Error; err != nil {
+		return 0, err
+	}
+	q := tx.Where("id = ?", id).Delete(&db.User{})
+	if q.Error != nil {
+		return 0, q.Error
+	}
+	return int32(q.RowsAffected), nil
+}
+
+func SetRole(ctx context.Context, _id graphql.ID, role string) (n int32, err error) {
+	if err = db.ValidateRole(role); err != nil {
+		return 0, err
+	}
+	id, err := common.DecodeCursor(_id)
+	if err != nil {
+		return 0, err
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	if role != db.RoleAdmin {
+		if err = EnsureOtherAdmin(tx, id); err != nil {
+			return 0, err
+		}
+	}
+	q := tx.Model(&db.User{ID: id}).Update("role", role)
+	if q.Error != nil {
+		return 0, q.Error
+	}
+	if q.RowsAffected == 0 {
+		return 0, fmt.Errorf("no such user")
+	}
+	return int32(q.RowsAffected), nil
+}
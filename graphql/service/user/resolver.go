@@ -6,13 +6,21 @@
 package user
 
 import (
+	"strings"
+
+	"github.com/daeuniverse/dae-wing/common"
 	"github.com/daeuniverse/dae-wing/db"
+	"github.com/graph-gophers/graphql-go"
 )
 
 type Resolver struct {
 	User *db.User
 }
 
+func (r *Resolver) ID() graphql.ID {
+	return common.EncodeCursor(r.User.ID)
+}
+
 func (r *Resolver) Username() string {
 	return r.User.Username
 }
@@ -24,3 +32,16 @@ func (r *Resolver) Name() *string {
 func (r *Resolver) Avatar() *string {
 	return r.User.Avatar
 }
+
+func (r *Resolver) Role() string {
+	return strings.ToUpper(r.User.Role)
+}
+
+func (r *Resolver) TotpEnabled() bool {
+	return r.User.TotpEnabled
+}
+
+type TotpEnrollment struct {
+	Secret string
+	Uri    string
+}
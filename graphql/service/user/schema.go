@@ -8,9 +8,18 @@ package user
 func Schema() (string, error) {
 	return `
 type User {
+	id: ID!
 	username: String!
 	name: String
 	avatar: String
+	role: Role!
+	totpEnabled: Boolean!
+}
+
+type TotpEnrollment {
+	secret: String!
+	# uri is the otpauth URI to be rendered as a QR code.
+	uri: String!
 }
 `, nil
 }
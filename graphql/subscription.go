@@ -0,0 +1,65 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphql
+
+import (
+	"context"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/event"
+	"github.com/daeuniverse/dae-wing/graphql/service/logs"
+)
+
+// subscriptionResolver resolves the GraphQL subscription root, not to be confused with node subscriptions.
+// Directives are not applied to subscription root fields, so roles are checked here.
+type subscriptionResolver struct{}
+
+func (r *subscriptionResolver) ReloadEvents(ctx context.Context) (<-chan *event.ReloadResolver, error) {
+	if err := checkRole(ctx, db.RoleViewer); err != nil {
+		return nil, err
+	}
+	return event.Reloads(ctx), nil
+}
+
+func (r *subscriptionResolver) SubscriptionUpdateEvents(ctx context.Context) (<-chan *event.SubscriptionUpdateResolver, error) {
+	if err := checkRole(ctx, db.RoleViewer); err != nil {
+		return nil, err
+	}
+	return event.SubscriptionUpdates(ctx), nil
+}
+
+func (r *subscriptionResolver) RunningStateEvents(ctx context.Context) (<-chan *event.RunningStateResolver, error) {
+	if err := checkRole(ctx, db.RoleViewer); err != nil {
+		return nil, err
+	}
+	return event.RunningStates(ctx), nil
+}
+
+func (r *subscriptionResolver) Logs(ctx context.Context, args *struct {
+	Level *string
+	Text  *string
+}) (<-chan *logs.Resolver, error) {
+	if err := checkRole(ctx, db.RoleAdmin); err != nil {
+		return nil, err
+	}
+	filter, err := logFilter(args.Level, args.Text)
+	if err != nil {
+		return nil, err
+	}
+	entries := logs.Stream(ctx, filter)
+	ch := make(chan *logs.Resolver)
+	go func() {
+		defer close(ch)
+		for e := range entries {
+			select {
+			case ch <- &logs.Resolver{Entry: e}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,33 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/graphql/service/audit"
+	"github.com/daeuniverse/dae-wing/metrics"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace/tracer"
+)
+
+// metricsTracer records the latency of requests besides audit logs.
+type metricsTracer struct {
+	audit.Tracer
+}
+
+var _ tracer.Tracer = metricsTracer{}
+
+func (t metricsTracer) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, tracer.QueryFinishFunc) {
+	start := time.Now()
+	ctx, finish := t.Tracer.TraceQuery(ctx, queryString, operationName, variables, varTypes)
+	return ctx, func(errs []*errors.QueryError) {
+		finish(errs)
+		metrics.ObserveGraphql(operationName, time.Since(start))
+	}
+}
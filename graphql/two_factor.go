@@ -0,0 +1,234 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daeuniverse/dae-wing/db"
+	"github.com/daeuniverse/dae-wing/graphql/service/user"
+	"github.com/daeuniverse/dae-wing/pkg/totp"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	numberRecoveryCodes  = 10
+	recoveryCodeAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+	recoveryCodeLength   = 10
+)
+
+var (
+	ErrSecondFactorRequired = fmt.Errorf("two-factor authentication code required")
+	ErrIncorrectSecondCode  = fmt.Errorf("incorrect two-factor authentication code")
+)
+
+func normRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func hashRecoveryCode(code string) string {
+	h := sha256.Sum256([]byte(normRecoveryCode(code)))
+	return hex.EncodeToString(h[:])
+}
+
+// generateRecoveryCodes replaces recovery codes of the user with new ones.
+func generateRecoveryCodes(d *gorm.DB, userId uint) (codes []string, err error) {
+	if err = d.Where("user_id = ?", userId).Delete(&db.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	var models []db.RecoveryCode
+	for i := 0; i < numberRecoveryCodes; i++ {
+		code, err := gonanoid.Generate(recoveryCodeAlphabet, recoveryCodeLength)
+		if err != nil {
+			return nil, err
+		}
+		// xxxxx-xxxxx
+		code = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		codes = append(codes, code)
+		models = append(models, db.RecoveryCode{
+			CodeHash: hashRecoveryCode(code),
+			UserID:   userId,
+		})
+	}
+	if err = d.Create(&models).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifyTotp verifies a TOTP code against the secret and records its time step to prevent replay.
+func verifyTotp(d *gorm.DB, u *db.User, secret string, code string) error {
+	counter, ok, err := totp.Validate(secret, code, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok || counter <= u.TotpLastCounter {
+		return ErrIncorrectSecondCode
+	}
+	if err = d.Model(&db.User{ID: u.ID}).Update("totp_last_counter", counter).Error; err != nil {
+		return err
+	}
+	u.TotpLastCounter = counter
+	return nil
+}
+
+// verifySecondFactor checks the TOTP code or a recovery code if the user enabled two-factor authentication.
+// A recovery code is consumed once used.
+func verifySecondFactor(d *gorm.DB, u *db.User, code *string) error {
+	if !u.TotpEnabled {
+		return nil
+	}
+	if code == nil || strings.TrimSpace(*code) == "" {
+		return ErrSecondFactorRequired
+	}
+	err := verifyTotp(d, u, u.TotpSecret, *code)
+	if !errors.Is(err, ErrIncorrectSecondCode) {
+		return err
+	}
+	// Try recovery codes.
+	q := d.Where("user_id = ?", u.ID).
+		Where("code_hash = ?", hashRecoveryCode(*code)).
+		Delete(&db.RecoveryCode{})
+	if q.Error != nil {
+		return q.Error
+	}
+	if q.RowsAffected == 0 {
+		return ErrIncorrectSecondCode
+	}
+	return nil
+}
+
+// DisableTotp turns off two-factor authentication for the user and removes its recovery codes.
+func DisableTotp(ctx context.Context, u *db.User) (err error) {
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	if err = tx.Model(&db.User{ID: u.ID}).Updates(map[string]interface{}{
+		"totp_secret":       "",
+		"totp_enabled":      false,
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		return err
+	}
+	if err = tx.Where("user_id = ?", u.ID).Delete(&db.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *MutationResolver) EnrollTotp(ctx context.Context) (*user.TotpEnrollment, error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if u.TotpEnabled {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err = db.DB(ctx).Model(&db.User{ID: u.ID}).Update("totp_secret", secret).Error; err != nil {
+		return nil, err
+	}
+	return &user.TotpEnrollment{
+		Secret: secret,
+		Uri:    totp.URI(db.AppName, u.Username, secret),
+	}, nil
+}
+
+func (r *MutationResolver) EnableTotp(ctx context.Context, args *struct {
+	Code string
+}) (codes []string, err error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if u.TotpEnabled {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+	if u.TotpSecret == "" {
+		return nil, fmt.Errorf("please enroll first")
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	if err = verifyTotp(tx, u, u.TotpSecret, args.Code); err != nil {
+		return nil, err
+	}
+	if err = tx.Model(&db.User{ID: u.ID}).Update("totp_enabled", true).Error; err != nil {
+		return nil, err
+	}
+	return generateRecoveryCodes(tx, u.ID)
+}
+
+func (r *MutationResolver) DisableTotp(ctx context.Context, args *struct {
+	Password string
+	Code     string
+}) (int32, error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !u.TotpEnabled {
+		return 0, nil
+	}
+	ok, _, err := verifyPassword(u, args.Password)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("incorrect password")
+	}
+	if err = verifySecondFactor(db.DB(ctx), u, &args.Code); err != nil {
+		return 0, err
+	}
+	if err = DisableTotp(ctx, u); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (r *MutationResolver) RegenerateRecoveryCodes(ctx context.Context, args *struct {
+	Code string
+}) (codes []string, err error) {
+	u, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !u.TotpEnabled {
+		return nil, fmt.Errorf("two-factor authentication is not enabled")
+	}
+	tx := db.BeginTx(ctx)
+	defer func() {
+		if err == nil {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}()
+	if err = verifyTotp(tx, u, u.TotpSecret, args.Code); err != nil {
+		return nil, err
+	}
+	return generateRecoveryCodes(tx, u.ID)
+}
@@ -0,0 +1,129 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/daeuniverse/dae-wing/db"
+)
+
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) header(name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, typ)
+}
+
+func (w *writer) sample(name, labels string, v float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%v%v %v\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (w *writer) histogram(name, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, b := range h.buckets {
+		w.sample(name+"_bucket", fmt.Sprintf(`%v%vle="%v"`, labels, sep, strconv.FormatFloat(b, 'g', -1, 64)), float64(h.counts[i]))
+	}
+	w.sample(name+"_bucket", fmt.Sprintf(`%v%vle="+Inf"`, labels, sep), float64(h.count))
+	w.sample(name+"_sum", labels, h.sum)
+	w.sample(name+"_count", labels, float64(h.count))
+}
+
+func bool2float(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// gauges writes metrics read from the database.
+func gauges(ctx context.Context, w *writer) error {
+	d := db.DB(ctx)
+	var sys db.System
+	if err := d.Model(&db.System{}).Select("running").Limit(1).Find(&sys).Error; err != nil {
+		return err
+	}
+	w.header("dae_wing_running", "gauge", "Whether dae is running a config.")
+	w.sample("dae_wing_running", "", bool2float(sys.Running))
+
+	for _, c := range []struct {
+		name  string
+		help  string
+		model interface{}
+	}{
+		{"dae_wing_nodes", "Number of nodes.", &db.Node{}},
+		{"dae_wing_groups", "Number of groups.", &db.Group{}},
+		{"dae_wing_subscriptions", "Number of subscriptions.", &db.Subscription{}},
+	} {
+		var n int64
+		if err := d.Model(c.model).Count(&n).Error; err != nil {
+			return err
+		}
+		w.header(c.name, "gauge", c.help)
+		w.sample(c.name, "", float64(n))
+	}
+	return nil
+}
+
+func write(w *writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	w.header("dae_wing_reloads_total", "counter", "Reloads of dae by result.")
+	for _, r := range []string{ResultSuccess, ResultFailure} {
+		w.sample("dae_wing_reloads_total", fmt.Sprintf(`result="%v"`, r), float64(reloads[r]))
+	}
+	w.header("dae_wing_reload_duration_seconds", "histogram", "Time taken to reload dae.")
+	w.histogram("dae_wing_reload_duration_seconds", "", reloadDuration)
+
+	w.header("dae_wing_subscription_updates_total", "counter", "Updates of subscriptions by result.")
+	for _, k := range sortedKeys(subscriptionUpdates, func(a, b subscriptionUpdateKey) bool {
+		if a.id != b.id {
+			return a.id < b.id
+		}
+		if a.tag != b.tag {
+			return a.tag < b.tag
+		}
+		return a.result < b.result
+	}) {
+		w.sample("dae_wing_subscription_updates_total", fmt.Sprintf(`subscription_id="%v",tag="%v",result="%v"`,
+			escapeLabel(k.id), escapeLabel(k.tag), k.result), float64(subscriptionUpdates[k]))
+	}
+
+	w.header("dae_wing_graphql_request_duration_seconds", "histogram", "Time taken to serve GraphQL requests by operation name.")
+	for _, op := range sortedKeys(graphqlDuration, func(a, b string) bool { return a < b }) {
+		w.histogram("dae_wing_graphql_request_duration_seconds", fmt.Sprintf(`operation="%v"`, escapeLabel(op)), graphqlDuration[op])
+	}
+}
+
+// Handler serves metrics to users with the VIEWER role, typically authenticated by an api token.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role, ok := r.Context().Value("role").(string); !ok || !db.RoleSatisfies(role, db.RoleViewer) {
+			http.Error(w, `access denied, "VIEWER" role required`, http.StatusUnauthorized)
+			return
+		}
+		var buf writer
+		if err := gauges(r.Context(), &buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		write(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	})
+}
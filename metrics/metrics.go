@@ -0,0 +1,135 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+// Package metrics exposes metrics of dae-wing in the Prometheus text format.
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// maxOperations limits distinct operation names, which are given by clients. Others are counted as OtherOperation.
+const maxOperations = 200
+
+const (
+	AnonymousOperation = "anonymous"
+	OtherOperation     = "other"
+)
+
+var (
+	reloadBuckets  = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	graphqlBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+var (
+	mu                  sync.Mutex
+	reloads             = map[string]uint64{}
+	reloadDuration      = newHistogram(reloadBuckets)
+	subscriptionUpdates = map[subscriptionUpdateKey]uint64{}
+	graphqlDuration     = map[string]*histogram{}
+)
+
+type subscriptionUpdateKey struct {
+	id     string
+	tag    string
+	result string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// ObserveReload records a reload of dae that took d.
+func ObserveReload(d time.Duration, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	reloads[result(err)]++
+	reloadDuration.observe(d.Seconds())
+}
+
+// ObserveSubscriptionUpdate records an update of the subscription with the id and the tag.
+func ObserveSubscriptionUpdate(id string, tag *string, err error) {
+	key := subscriptionUpdateKey{
+		id:     id,
+		result: result(err),
+	}
+	if tag != nil {
+		key.tag = *tag
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	subscriptionUpdates[key]++
+}
+
+// ObserveGraphql records a GraphQL request of the operation that took d.
+func ObserveGraphql(operation string, d time.Duration) {
+	if operation == "" {
+		operation = AnonymousOperation
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	h, ok := graphqlDuration[operation]
+	if !ok {
+		if len(graphqlDuration) >= maxOperations {
+			operation = OtherOperation
+			h = graphqlDuration[operation]
+		}
+		if h == nil {
+			h = newHistogram(graphqlBuckets)
+			graphqlDuration[operation] = h
+		}
+	}
+	h.observe(d.Seconds())
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, less func(a, b K) bool) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+	return keys
+}
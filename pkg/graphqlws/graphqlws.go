@@ -0,0 +1,272 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+// Package graphqlws serves GraphQL over WebSocket with the graphql-transport-ws protocol of graphql-ws.
+// See https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+package graphqlws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const Protocol = "graphql-transport-ws"
+
+const DefaultInitTimeout = 10 * time.Second
+
+const (
+	msgConnectionInit = "connection_init"
+	msgConnectionAck  = "connection_ack"
+	msgPing           = "ping"
+	msgPong           = "pong"
+	msgSubscribe      = "subscribe"
+	msgNext           = "next"
+	msgError          = "error"
+	msgComplete       = "complete"
+)
+
+const (
+	closeBadRequest        = 4400
+	closeUnauthorized      = 4401
+	closeForbidden         = 4403
+	closeBadProtocol       = 4406
+	closeInitTimeout       = 4408
+	closeSubscriberExists  = 4409
+	closeTooManyInitialise = 4429
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{Protocol},
+	// Credentials are carried in headers or the init payload rather than cookies, so any origin is fine, the same as
+	// the CORS policy of the HTTP endpoint.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// IsUpgrade reports whether r asks to switch to WebSocket.
+func IsUpgrade(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
+
+type Server struct {
+	// Schema returns the schema to execute operations of the connection with.
+	Schema func(ctx context.Context) *graphql.Schema
+	// Init is called with the payload of connection_init and returns the context of the connection. Returning an
+	// error rejects the connection.
+	Init func(ctx context.Context, payload map[string]interface{}) (context.Context, error)
+	// InitTimeout is how long to wait for connection_init. Zero means DefaultInitTimeout.
+	InitTimeout time.Duration
+}
+
+type message struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type subscribePayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has replied with the error.
+		return
+	}
+	c := &connection{
+		server: s,
+		conn:   conn,
+		subs:   map[string]context.CancelFunc{},
+	}
+	if conn.Subprotocol() != Protocol {
+		c.close(closeBadProtocol, "Subprotocol not acceptable")
+		return
+	}
+	c.serve(r.Context())
+}
+
+type connection struct {
+	server  *Server
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	ctx    context.Context
+	acked  bool
+	schema *graphql.Schema
+	subs   map[string]context.CancelFunc
+}
+
+func (c *connection) serve(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	defer c.conn.Close()
+
+	initTimeout := c.server.InitTimeout
+	if initTimeout <= 0 {
+		initTimeout = DefaultInitTimeout
+	}
+	timer := time.AfterFunc(initTimeout, func() {
+		c.mu.Lock()
+		acked := c.acked
+		c.mu.Unlock()
+		if !acked {
+			c.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer timer.Stop()
+
+	for {
+		var msg message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				c.close(closeBadRequest, "Invalid message received")
+			}
+			return
+		}
+		switch msg.Type {
+		case msgConnectionInit:
+			if !c.init(ctx, msg.Payload) {
+				return
+			}
+		case msgPing:
+			c.write(&message{Type: msgPong})
+		case msgPong:
+		case msgSubscribe:
+			if !c.subscribe(msg.ID, msg.Payload) {
+				return
+			}
+		case msgComplete:
+			c.mu.Lock()
+			if stop, ok := c.subs[msg.ID]; ok {
+				stop()
+				delete(c.subs, msg.ID)
+			}
+			c.mu.Unlock()
+		default:
+			c.close(closeBadRequest, fmt.Sprintf("Invalid message type %q", msg.Type))
+			return
+		}
+	}
+}
+
+func (c *connection) init(ctx context.Context, rawPayload json.RawMessage) (ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx != nil {
+		c.close(closeTooManyInitialise, "Too many initialisation requests")
+		return false
+	}
+	var payload map[string]interface{}
+	if len(rawPayload) > 0 {
+		if err := json.Unmarshal(rawPayload, &payload); err != nil {
+			c.close(closeBadRequest, "Invalid connection_init payload")
+			return false
+		}
+	}
+	if c.server.Init != nil {
+		var err error
+		if ctx, err = c.server.Init(ctx, payload); err != nil {
+			c.close(closeForbidden, "Forbidden")
+			return false
+		}
+	}
+	c.ctx = ctx
+	c.schema = c.server.Schema(ctx)
+	c.acked = true
+	c.write(&message{Type: msgConnectionAck})
+	return true
+}
+
+func (c *connection) subscribe(id string, rawPayload json.RawMessage) (ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.acked {
+		c.close(closeUnauthorized, "Unauthorized")
+		return false
+	}
+	var payload subscribePayload
+	if id == "" || json.Unmarshal(rawPayload, &payload) != nil {
+		c.close(closeBadRequest, "Invalid subscribe message")
+		return false
+	}
+	if _, exists := c.subs[id]; exists {
+		c.close(closeSubscriberExists, fmt.Sprintf("Subscriber for %v already exists", id))
+		return false
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.subs[id] = cancel
+	go c.run(ctx, id, &payload)
+	return true
+}
+
+func (c *connection) run(ctx context.Context, id string, payload *subscribePayload) {
+	defer func() {
+		c.mu.Lock()
+		if stop, ok := c.subs[id]; ok {
+			stop()
+			delete(c.subs, id)
+		}
+		c.mu.Unlock()
+	}()
+	// Queries and mutations are executed by Subscribe without tracing, which would bypass the audit log and
+	// metrics of the HTTP endpoint.
+	if operationType(payload.Query, payload.OperationName) != "subscription" {
+		c.writeErrors(id, []map[string]string{{"message": "only subscriptions are served over WebSocket; use HTTP for queries and mutations"}})
+		return
+	}
+	responses, err := c.schema.Subscribe(ctx, payload.Query, payload.OperationName, payload.Variables)
+	if err != nil {
+		c.writeErrors(id, []map[string]string{{"message": err.Error()}})
+		return
+	}
+	for r := range responses {
+		resp := r.(*graphql.Response)
+		if resp.Data == nil && len(resp.Errors) > 0 {
+			// Failed before execution, e.g. validation errors.
+			c.writeErrors(id, resp.Errors)
+			return
+		}
+		b, err := json.Marshal(resp)
+		if err != nil {
+			c.writeErrors(id, []map[string]string{{"message": err.Error()}})
+			return
+		}
+		c.write(&message{ID: id, Type: msgNext, Payload: b})
+	}
+	if ctx.Err() == nil {
+		c.write(&message{ID: id, Type: msgComplete})
+	}
+}
+
+func (c *connection) writeErrors(id string, errors interface{}) {
+	b, _ := json.Marshal(errors)
+	c.write(&message{ID: id, Type: msgError, Payload: b})
+}
+
+func (c *connection) write(msg *message) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.WriteJSON(msg)
+}
+
+// close sends a close frame with the code and closes the connection.
+func (c *connection) close(code int, reason string) {
+	_ = c.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second),
+	)
+	_ = c.conn.Close()
+}
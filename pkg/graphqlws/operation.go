@@ -0,0 +1,122 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphqlws
+
+import (
+	"strings"
+)
+
+// operationType returns the type of the operation to execute in the document: "query", "mutation" or
+// "subscription". It returns "" if the operation cannot be determined, e.g. the document is malformed or
+// operationName does not match exactly one operation.
+//
+// The parser of graphql-go is internal, so the document is scanned for top-level operation definitions here.
+func operationType(document string, operationName string) string {
+	type operation struct {
+		typ  string
+		name string
+	}
+	var (
+		ops   []operation
+		depth int
+		// keyword is the keyword of the definition being read at the top level.
+		keyword   string
+		named     bool
+		directive bool
+	)
+	for i := 0; i < len(document); {
+		ch := document[i]
+		switch {
+		case ch == '#':
+			for i < len(document) && document[i] != '\n' && document[i] != '\r' {
+				i++
+			}
+		case ch == '"':
+			if strings.HasPrefix(document[i:], `"""`) {
+				for i += 3; !strings.HasPrefix(document[i:], `"""`); i++ {
+					if i >= len(document) {
+						return ""
+					}
+					if strings.HasPrefix(document[i:], `\"""`) {
+						i += 3
+					}
+				}
+				i += 3
+				continue
+			}
+			i++
+			for ; i < len(document) && document[i] != '"'; i++ {
+				if document[i] == '\\' {
+					i++
+				} else if document[i] == '\n' || document[i] == '\r' {
+					return ""
+				}
+			}
+			if i >= len(document) {
+				return ""
+			}
+			i++
+		case ch == '{' || ch == '(' || ch == '[':
+			if depth == 0 && ch == '{' && keyword == "" {
+				// A query shorthand.
+				ops = append(ops, operation{typ: "query"})
+			}
+			depth++
+			i++
+		case ch == '}' || ch == ')' || ch == ']':
+			depth--
+			if depth < 0 {
+				return ""
+			}
+			if depth == 0 && ch == '}' {
+				keyword, named = "", false
+			}
+			i++
+		case ch == '@':
+			directive = true
+			i++
+		case ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z':
+			start := i
+			for i < len(document) && (document[i] == '_' || document[i] >= 'a' && document[i] <= 'z' ||
+				document[i] >= 'A' && document[i] <= 'Z' || document[i] >= '0' && document[i] <= '9') {
+				i++
+			}
+			if depth > 0 {
+				continue
+			}
+			name := document[start:i]
+			switch {
+			case directive:
+				directive = false
+			case keyword == "":
+				keyword = name
+				if name == "query" || name == "mutation" || name == "subscription" {
+					ops = append(ops, operation{typ: name})
+				}
+			case !named && (keyword == "query" || keyword == "mutation" || keyword == "subscription"):
+				named = true
+				ops[len(ops)-1].name = name
+			}
+		default:
+			i++
+		}
+	}
+	if depth != 0 {
+		return ""
+	}
+	var typ string
+	found := 0
+	for _, op := range ops {
+		if operationName == "" || op.name == operationName {
+			typ = op.typ
+			found++
+		}
+	}
+	if found != 1 {
+		return ""
+	}
+	return typ
+}
@@ -0,0 +1,41 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package graphqlws
+
+import (
+	"testing"
+)
+
+func TestOperationType(t *testing.T) {
+	tests := []struct {
+		name          string
+		document      string
+		operationName string
+		want          string
+	}{
+		{name: "shorthand", document: `{ general { dae { running } } }`, want: "query"},
+		{name: "subscription", document: `subscription { nodeHealth { id } }`, want: "subscription"},
+		{name: "named mutation", document: `mutation Run($dry: Boolean!) { run(dry: $dry) }`, want: "mutation"},
+		{name: "directive before selection", document: `subscription @foo(a: "{") { x }`, want: "subscription"},
+		{name: "keyword in comment", document: "# subscription\nmutation { run(dry: false) }", want: "mutation"},
+		{name: "keyword in string", document: `mutation { a(s: "subscription { x }") }`, want: "mutation"},
+		{name: "keyword in block string", document: `mutation { a(s: """subscription \""" { x }""") }`, want: "mutation"},
+		{name: "object default value", document: `query Q($f: In = {a: 1}) { x }`, want: "query"},
+		{name: "fragment", document: `fragment F on Node { id } subscription S { x { ...F } }`, want: "subscription"},
+		{name: "selected by name", document: `subscription S { x } mutation M { y }`, operationName: "M", want: "mutation"},
+		{name: "ambiguous", document: `subscription S { x } mutation M { y }`},
+		{name: "unknown name", document: `subscription S { x }`, operationName: "T"},
+		{name: "unbalanced", document: `subscription { x `},
+		{name: "unterminated string", document: `subscription { x(s: "a) }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := operationType(tt.document, tt.operationName); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientId     = "wing"
+	testClientSecret = "secret"
+	testRedirectUri  = "http://127.0.0.1/callback"
+	testKid          = "k1"
+)
+
+// mockIssuer is an OpenID provider that issues codes for authorization requests registered by authorize.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// codes maps a code to the PKCE challenge of its authorization request.
+	codes map[string]string
+	// claims are claims of the ID token issued for a code.
+	claims jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{t: t, key: key, codes: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize plays the user agent visiting the authorization URL and returns the code.
+func (m *mockIssuer) authorize(authUrl string) (code string, nonce string) {
+	u, err := url.Parse(authUrl)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientId {
+		m.t.Fatalf("unexpected authorization request: %v", authUrl)
+	}
+	code = "code-" + q.Get("state")
+	m.mu.Lock()
+	m.codes[code] = q.Get("code_challenge")
+	m.mu.Unlock()
+	return code, q.Get("nonce")
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(e string) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": e})
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != testClientId || secret != testClientSecret {
+		fail("invalid_client")
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		fail("invalid_request")
+		return
+	}
+	m.mu.Lock()
+	challenge, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		fail("invalid_grant")
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(m.claims, m.key)})
+}
+
+func (m *mockIssuer) sign(claims jwt.MapClaims, key *rsa.PrivateKey) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKid
+	s, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return s
+}
+
+func (m *mockIssuer) validClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    m.server.URL,
+		"sub":    "alice-id",
+		"aud":    testClientId,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"iat":    time.Now().Unix(),
+		"nonce":  nonce,
+		"groups": []string{"ops"},
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	m := newMockIssuer(t)
+	p, err := Discover(context.Background(), nil, m.server.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TokenEndpoint != m.server.URL+"/token" || p.JwksUri != m.server.URL+"/jwks" {
+		t.Fatalf("unexpected provider: %+v", p)
+	}
+	other := httptest.NewServer(m.server.Config.Handler)
+	defer other.Close()
+	if _, err = Discover(context.Background(), nil, other.URL); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Fatalf("expected issuer mismatch, got %v", err)
+	}
+}
+
+func TestCodeFlow(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+	p, err := Discover(ctx, m.server.Client(), m.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := NewState()
+	nonce, _ := NewState()
+	verifier, _ := NewVerifier()
+	code, gotNonce := m.authorize(p.AuthCodeURL(testClientId, testRedirectUri, []string{"openid"}, state, nonce, verifier))
+	if gotNonce != nonce {
+		t.Fatalf("nonce is not sent: %v", gotNonce)
+	}
+	m.claims = m.validClaims(nonce)
+	idToken, err := p.Exchange(ctx, testClientId, testClientSecret, code, testRedirectUri, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.Verify(ctx, idToken, testClientId, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub, _ := claims.GetSubject(); sub != "alice-id" {
+		t.Fatalf("unexpected subject: %v", sub)
+	}
+	// The code is used up.
+	if _, err = p.Exchange(ctx, testClientId, testClientSecret, code, testRedirectUri, verifier); err == nil {
+		t.Fatal("expected a used code to be rejected")
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+	p, err := Discover(ctx, m.server.Client(), m.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, _ := NewVerifier()
+	other, _ := NewVerifier()
+	code, nonce := m.authorize(p.AuthCodeURL(testClientId, testRedirectUri, []string{"openid"}, "s", "n", verifier))
+	m.claims = m.validClaims(nonce)
+	_, err = p.Exchange(ctx, testClientId, testClientSecret, code, testRedirectUri, other)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("expected invalid_grant, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+	p, err := Discover(ctx, m.server.Client(), m.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const nonce = "n1"
+	tests := []struct {
+		name    string
+		modify  func(c jwt.MapClaims)
+		key     *rsa.PrivateKey
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "nonce mismatch", modify: func(c jwt.MapClaims) { c["nonce"] = "n2" }, wantErr: "nonce mismatch"},
+		{name: "no nonce", modify: func(c jwt.MapClaims) { delete(c, "nonce") }, wantErr: "nonce mismatch"},
+		{name: "audience mismatch", modify: func(c jwt.MapClaims) { c["aud"] = "other" }, wantErr: "aud"},
+		{name: "audience list", modify: func(c jwt.MapClaims) { c["aud"] = []string{"other", testClientId} }},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: "expired"},
+		{name: "expired within leeway", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-30 * time.Second).Unix() }},
+		{name: "no exp", modify: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: "no exp"},
+		{name: "issuer mismatch", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, wantErr: "iss"},
+		{name: "no sub", modify: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: "no sub"},
+		{name: "bad signature", key: otherKey, wantErr: "signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := m.validClaims(nonce)
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			key := m.key
+			if tt.key != nil {
+				key = tt.key
+			}
+			_, err := p.Verify(ctx, m.sign(claims, key), testClientId, nonce)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed "12345678901234567890" of the test vectors in RFC 6238 Appendix B.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeAt(t *testing.T) {
+	// The last 6 digits of the 8-digit codes of RFC 6238.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		got, err := CodeAt(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Fatalf("%v: expected %v, got %v", tt.unix, tt.want, got)
+		}
+	}
+	if _, err := CodeAt("not base32!", 1); err == nil {
+		t.Fatal("expected an error for a bad secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	counter := Counter(now)
+	code := func(c uint64) string {
+		s, err := CodeAt(rfcSecret, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		want   bool
+	}{
+		{name: "current step", secret: rfcSecret, code: code(counter), want: true},
+		{name: "previous step", secret: rfcSecret, code: code(counter - 1), want: true},
+		{name: "next step", secret: rfcSecret, code: code(counter + 1), want: true},
+		{name: "out of skew", secret: rfcSecret, code: code(counter - 2)},
+		{name: "surrounding spaces", secret: rfcSecret, code: " " + code(counter) + " ", want: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: code(counter), want: true},
+		{name: "wrong length", secret: rfcSecret, code: code(counter)[:5]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := Validate(tt.secret, tt.code, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, ok)
+			}
+			if ok && (got+1 < counter || got > counter+1) {
+				t.Fatalf("unexpected matched step %v around %v", got, counter)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 32 || a == b {
+		t.Fatalf("unexpected secrets: %v %v", a, b)
+	}
+	if _, err = CodeAt(a, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("dae-wing", "alice", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/dae-wing:alice" ||
+		q.Get("secret") != rfcSecret || q.Get("issuer") != "dae-wing" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("unexpected uri: %v", u)
+	}
+}
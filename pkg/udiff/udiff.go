@@ -0,0 +1,234 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+// Package udiff produces line-based unified diffs.
+package udiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxEditDistance bounds the work of the Myers algorithm. Texts differing more than it are diffed as a whole replacement.
+const maxEditDistance = 2000
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Unified returns the unified diff from a to b with given lines of context. It returns an empty string if their lines
+// are equal.
+func Unified(fromName string, toName string, a string, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := diff(splitLines(a), splitLines(b))
+	if !hasChange(ops) {
+		// They differ only in the trailing newline.
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %v\n+++ %v\n", fromName, toName)
+	// Find hunks.
+	for i := 0; i < len(ops); {
+		// Skip to the next change.
+		for i < len(ops) && ops[i].kind == opEqual {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// Extend the hunk until there are more than 2*context equal lines in a row.
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			j := end
+			for j < len(ops) && ops[j].kind == opEqual {
+				j++
+			}
+			if j == len(ops) || j-end > 2*context {
+				end += min(context, j-end)
+				break
+			}
+			end = j
+		}
+		writeHunk(&sb, ops, start, end)
+		i = end
+	}
+	return sb.String()
+}
+
+func hasChange(ops []op) bool {
+	for _, o := range ops {
+		if o.kind != opEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func writeHunk(sb *strings.Builder, ops []op, start int, end int) {
+	var aLine, bLine int
+	for _, o := range ops[:start] {
+		if o.kind != opInsert {
+			aLine++
+		}
+		if o.kind != opDelete {
+			bLine++
+		}
+	}
+	var aCount, bCount int
+	for _, o := range ops[start:end] {
+		if o.kind != opInsert {
+			aCount++
+		}
+		if o.kind != opDelete {
+			bCount++
+		}
+	}
+	if aCount > 0 {
+		aLine++
+	}
+	if bCount > 0 {
+		bLine++
+	}
+	fmt.Fprintf(sb, "@@ -%v,%v +%v,%v @@\n", aLine, aCount, bLine, bCount)
+	for _, o := range ops[start:end] {
+		sb.WriteByte(byte(o.kind))
+		sb.WriteString(o.line)
+		sb.WriteByte('\n')
+	}
+}
+
+// diff returns the shortest edit script from a to b.
+func diff(a []string, b []string) []op {
+	// Trim the common prefix and suffix, which is the most of a typical config change.
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ops := make([]op, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		ops = append(ops, op{opEqual, l})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, op{opEqual, l})
+	}
+	return ops
+}
+
+func replaceAll(a []string, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	for _, l := range a {
+		ops = append(ops, op{opDelete, l})
+	}
+	for _, l := range b {
+		ops = append(ops, op{opInsert, l})
+	}
+	return ops
+}
+
+// myers implements "An O(ND) Difference Algorithm and Its Variations" by Eugene W. Myers.
+func myers(a []string, b []string) []op {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+	maxD := n + m
+	if maxD > maxEditDistance {
+		maxD = maxEditDistance
+	}
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	// trace[d] keeps v[-d..d] before step d.
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+func backtrack(a []string, b []string, trace [][]int) []op {
+	x, y := len(a), len(b)
+	var ops []op
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		var prevX int
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, op{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{opInsert, b[y-1]})
+				y--
+			} else {
+				ops = append(ops, op{opDelete, a[x-1]})
+				x--
+			}
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
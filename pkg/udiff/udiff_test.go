@@ -0,0 +1,97 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ * Copyright (c) 2023, daeuniverse Organization <team@v2raya.org>
+ */
+
+package udiff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{name: "equal", a: "a\nb\n", b: "a\nb\n"},
+		{name: "trailing newline only", a: "a\nb", b: "a\nb\n"},
+		{name: "change", a: "a\nb\nc\n", b: "a\nB\nc\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{name: "from empty", a: "", b: "a\nb\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{name: "to empty", a: "a\nb\n", b: "",
+			want: "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{name: "delete first line", a: "x\na\nb\n", b: "a\nb\n",
+			want: "--- a\n+++ b\n@@ -1,2 +1,1 @@\n-x\n a\n"},
+		{name: "separate hunks", a: "1\n2\n3\n4\n5\n6\n7\n8\n9\n", b: "1\nX\n3\n4\n5\n6\n7\nY\n9\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n+X\n 3\n@@ -7,3 +7,3 @@\n 7\n-8\n+Y\n 9\n"},
+		{name: "merged hunks", a: "1\n2\n3\n4\n5\n6\n", b: "1\nX\n3\n4\nY\n6\n",
+			want: "--- a\n+++ b\n@@ -1,6 +1,6 @@\n 1\n-2\n+X\n 3\n 4\n-5\n+Y\n 6\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("a", "b", tt.a, tt.b, 1); got != tt.want {
+				t.Fatalf("expected:\n%v\ngot:\n%v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestDiff checks that edit scripts of random texts rebuild both texts and are no longer than the distance of
+// the longest common subsequence.
+func TestDiff(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = fmt.Sprint(r.Intn(5))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diff(a, b)
+		var gotA, gotB []string
+		var edits int
+		for _, o := range ops {
+			if o.kind != opInsert {
+				gotA = append(gotA, o.line)
+			}
+			if o.kind != opDelete {
+				gotB = append(gotB, o.line)
+			}
+			if o.kind != opEqual {
+				edits++
+			}
+		}
+		if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+			t.Fatalf("edit script does not rebuild %q and %q: %v", a, b, ops)
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Fatalf("expected %v edits from %q to %q, got %v", want, a, b, edits)
+		}
+	}
+}
+
+func lcs(a []string, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}